@@ -7,11 +7,34 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"time"
+
+	"github.com/golang-jwt/jwt/v5"
 )
 
 const baseURL = "http://localhost:8080"
 
+// Tokens are signed with the server's HS256 JWT_SECRET.
+var userToken, adminToken string
+
+func token(sub, role string) string {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		log.Fatal("JWT_SECRET is required to sign test tokens")
+	}
+	t := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":  sub,
+		"role": role,
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	})
+	s, err := t.SignedString([]byte(secret))
+	if err != nil {
+		log.Fatalf("sign token: %v", err)
+	}
+	return s
+}
+
 func main() {
 	// Wait for server to start
 	time.Sleep(2 * time.Second)
@@ -21,6 +44,11 @@ func main() {
 
 	// 2. Create Reward
 	userID := "e2e-user"
+	userToken = token(userID, "user")
+	adminToken = token("e2e-admin", "admin")
+
+	// Users may not grant rewards or read other users' data.
+	checkEndpoint("GET", "/portfolio/someone-else", nil, 403)
 	rewardID := createReward(userID)
 	fmt.Printf("Created Reward ID: %s\n", rewardID)
 
@@ -54,6 +82,7 @@ func checkEndpoint(method, path string, body interface{}, expectedStatus int) {
 	}
 
 	req, _ := http.NewRequest(method, baseURL+path, bodyReader)
+	req.Header.Set("Authorization", "Bearer "+userToken)
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
@@ -82,7 +111,10 @@ func createReward(userID string) string {
 		"source":          "e2e-test",
 	}
 	jsonBody, _ := json.Marshal(reqBody)
-	resp, err := http.Post(baseURL+"/reward", "application/json", bytes.NewBuffer(jsonBody))
+	req, _ := http.NewRequest("POST", baseURL+"/reward", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Fatalf("Create reward failed: %v", err)
 	}
@@ -100,7 +132,10 @@ func createReward(userID string) string {
 
 func revertReward(rewardID string) {
 	fmt.Printf("Reverting reward %s...\n", rewardID)
-	req, _ := http.NewRequest("POST", baseURL+"/reward/"+rewardID+"/revert", nil)
+	body := []byte(`{"reason": "ops_error", "note": "e2e test reversal"}`)
+	req, _ := http.NewRequest("POST", baseURL+"/reward/"+rewardID+"/revert", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+adminToken)
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Fatalf("Revert failed: %v", err)
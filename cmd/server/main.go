@@ -2,15 +2,22 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"fmt"
 	"os"
 	"strconv"
 	"time"
+	_ "time/tzdata" // per-user timezones must resolve without system tzdata
 
+	"stocky/internal/auth"
+	"stocky/internal/calendar"
 	"stocky/internal/database"
+	"stocky/internal/events"
 	"stocky/internal/handlers"
+	"stocky/internal/policy"
 	"stocky/internal/service"
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 	"github.com/jmoiron/sqlx"
 	"github.com/joho/godotenv"
@@ -35,8 +42,54 @@ func main() {
 	}
 	defer db.Close()
 
-	r := database.New(db, logger)
-	priceSvc := service.NewCleanPriceService(r, logger)
+	holidaysFile := os.Getenv("MARKET_HOLIDAYS_FILE")
+	if holidaysFile == "" {
+		holidaysFile = "data/nse_holidays.csv"
+	}
+	holidays, err := calendar.LoadHolidays(holidaysFile)
+	if err != nil {
+		logger.Warnf("load market holidays from %s: %v; only weekends will be treated as closed", holidaysFile, err)
+	}
+	cal := calendar.NSE(holidays)
+
+	tz := os.Getenv("APP_TIMEZONE")
+	if tz == "" {
+		tz = calendar.DefaultTimezone
+	}
+	loc, err := calendar.LoadLocation(tz)
+	if err != nil {
+		logger.Fatalf("invalid APP_TIMEZONE: %v", err)
+	}
+
+	r := database.New(db, logger, database.WithCalendar(cal), database.WithTimezone(loc))
+	maxMove := decimal.NewFromInt(20)
+	if v := os.Getenv("PRICE_MAX_MOVE_PCT"); v != "" {
+		if d, err := decimal.NewFromString(v); err == nil && d.IsPositive() {
+			maxMove = d
+		}
+	}
+	guard := service.NewPriceGuard(r, logger, maxMove)
+
+	var priceSvc service.PriceProvider
+	switch os.Getenv("PRICE_PROVIDER") {
+	case "random":
+		priceSvc = service.NewCleanPriceService(r, logger, service.WithMarketCalendar(cal), service.WithPriceGuard(guard))
+	default:
+		simFile := os.Getenv("SIM_CONFIG_FILE")
+		if simFile == "" {
+			simFile = "data/sim_market.json"
+		}
+		simCfg, err := service.LoadSimConfig(simFile)
+		if err != nil {
+			logger.Warnf("load simulation config from %s: %v; using defaults", simFile, err)
+		}
+		if v := os.Getenv("SIM_SEED"); v != "" {
+			if seed, err := strconv.ParseInt(v, 10, 64); err == nil {
+				simCfg.Seed = seed
+			}
+		}
+		priceSvc = service.NewSimulatedPriceService(r, logger, simCfg, cal, guard)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -49,21 +102,142 @@ func main() {
 	}
 	priceSvc.Start(ctx, time.Duration(interval)*time.Second)
 
-	_ = r.EnsureStockExists(ctx, "RELIANCE", "Reliance Industries")
-	_ = r.EnsureStockExists(ctx, "TCS", "Tata Consultancy Services")
-	_ = r.EnsureStockExists(ctx, "INFY", "Infosys")
+	dispatcher := service.NewWebhookDispatcher(r, logger)
+	dispatcher.Start(ctx, 5*time.Second)
 
-	h := handlers.NewHandler(r, priceSvc, logger)
+	stocksFile := os.Getenv("STOCKS_FILE")
+	if stocksFile == "" {
+		stocksFile = "data/stocks.json"
+	}
+	if seeds, err := database.LoadStocks(stocksFile); err != nil {
+		logger.Warnf("load stock seeds from %s: %v; no stocks seeded", stocksFile, err)
+	} else if n, err := r.SeedStocks(ctx, seeds); err != nil {
+		logger.Warnf("seed stocks: %v", err)
+	} else if n > 0 {
+		logger.Infof("seeded %d stocks from %s", n, stocksFile)
+	}
+
+	var limits policy.Config
+	if f := os.Getenv("REWARD_LIMITS_FILE"); f != "" {
+		if limits, err = policy.Load(f); err != nil {
+			logger.Fatalf("load reward limits: %v", err)
+		}
+	}
+
+	approvalThreshold := decimal.Zero
+	if v := os.Getenv("REWARD_APPROVAL_THRESHOLD_INR"); v != "" {
+		if approvalThreshold, err = decimal.NewFromString(v); err != nil {
+			logger.Fatalf("invalid REWARD_APPROVAL_THRESHOLD_INR: %v", err)
+		}
+	}
+
+	rounding, err := service.ParseRounding(os.Getenv("REWARD_ROUNDING"))
+	if err != nil {
+		logger.Fatalf("invalid REWARD_ROUNDING: %v", err)
+	}
+
+	sellFeeRate := service.DefaultSellFeeRate
+	if v := os.Getenv("SELL_FEE_PCT"); v != "" {
+		pct, err := decimal.NewFromString(v)
+		if err != nil || pct.IsNegative() || pct.GreaterThan(decimal.NewFromInt(100)) {
+			logger.Fatalf("invalid SELL_FEE_PCT %q", v)
+		}
+		sellFeeRate = pct.Div(decimal.NewFromInt(100))
+	}
+
+	bus := events.NewBus(32)
+	vestingJob := service.NewVestingJob(r, logger, bus)
+	vestingJob.Start(ctx, time.Minute)
+
+	h := handlers.NewHandler(r, priceSvc, logger,
+		handlers.WithEventBus(bus),
+		handlers.WithCalendar(cal),
+		handlers.WithRewardLimits(limits),
+		handlers.WithApprovalThreshold(approvalThreshold),
+		handlers.WithRounding(rounding),
+		handlers.WithSellFeeRate(sellFeeRate),
+	)
+
+	authCfg, err := auth.ConfigFromEnv()
+	if err != nil {
+		logger.Fatalf("auth config: %v", err)
+	}
+	authn := auth.New(authCfg, auth.WithAPIKeys(r))
 
 	rg := gin.Default()
+	rg.Use(handlers.RequestID())
 	rg.GET("/health", func(c *gin.Context) { c.JSON(200, gin.H{"status": "ok"}) })
+	rg.GET("/market/status", h.GetMarketStatus)
+
+	api := rg.Group("/", authn.Middleware())
+
+	api.POST("/reward", auth.RequireRole(auth.RoleAdmin, auth.RoleService, auth.RolePartner), h.PostReward)
+
+	admin := api.Group("/", auth.RequireRole(auth.RoleAdmin, auth.RoleService))
+	admin.POST("/reward/:id/revert", h.RevertReward)
+	admin.GET("/prices/quarantine", h.GetQuarantinedPrices)
+	admin.PUT("/prices/:symbol", h.OverridePrice)
+	admin.GET("/audit", h.GetAuditEvents)
+	admin.GET("/audit/verify", h.VerifyAuditLog)
+	admin.POST("/webhooks", h.CreateWebhook)
+	admin.GET("/webhooks", h.ListWebhooks)
+	admin.GET("/webhooks/:id/deliveries", h.GetWebhookDeliveries)
+	admin.POST("/users/:userId/churn", h.ChurnUser)
+	admin.POST("/events", h.PostEvent)
+	admin.POST("/campaigns", h.CreateCampaign)
+	admin.GET("/campaigns", h.ListCampaigns)
+	admin.GET("/campaigns/:id", h.GetCampaign)
+	admin.PATCH("/campaigns/:id", h.SetCampaignActive)
+	admin.POST("/campaigns/:id/rules", h.CreateCampaignRule)
+	admin.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+
+	approvals := api.Group("/admin/approvals", auth.RequireRole(auth.RoleAdmin))
+	approvals.GET("", h.ListApprovals)
+	approvals.POST("/:rewardId/approve", h.ApproveReward)
+	approvals.POST("/:rewardId/reject", h.RejectReward)
+
+	withdrawals := api.Group("/admin/withdrawals", auth.RequireRole(auth.RoleAdmin, auth.RoleService))
+	withdrawals.GET("", h.ListWithdrawals)
+	withdrawals.GET("/:id", h.GetWithdrawal)
+	withdrawals.POST("/:id/process", h.ProcessWithdrawal)
+	withdrawals.POST("/:id/settle", h.SettleWithdrawal)
+	withdrawals.POST("/:id/fail", h.FailWithdrawal)
+
+	stocks := api.Group("/admin/stocks", auth.RequireRole(auth.RoleAdmin, auth.RoleService))
+	stocks.GET("", h.ListStocks)
+	stocks.POST("", h.CreateStock)
+	stocks.GET("/:symbol", h.GetStock)
+	stocks.PATCH("/:symbol", h.UpdateStock)
+	stocks.DELETE("/:symbol", h.DelistStock)
+
+	rewards := api.Group("/admin/rewards", auth.RequireRole(auth.RoleAdmin, auth.RoleService))
+	rewards.GET("", h.SearchRewards)
+	rewards.GET("/reversals", h.GetReversalReport)
+	rewards.GET("/:id", h.GetRewardDetail)
+
+	keys := api.Group("/admin/api-keys", auth.RequireRole(auth.RoleAdmin))
+	keys.POST("", h.CreateAPIKey)
+	keys.GET("", h.ListAPIKeys)
+	keys.POST("/:id/rotate", h.RotateAPIKey)
+	keys.DELETE("/:id", h.RevokeAPIKey)
+
+	api.POST("/transfers", auth.RequireRole(auth.RoleUser, auth.RoleAdmin, auth.RoleService), h.PostTransfer)
+	api.POST("/sell", auth.RequireRole(auth.RoleUser, auth.RoleAdmin, auth.RoleService), h.PostSell)
 
-	rg.POST("/reward", h.PostReward)
-	rg.POST("/reward/:id/revert", h.RevertReward)
-	rg.GET("/today-stocks/:userId", h.GetTodayStocks)
-	rg.GET("/stats/:userId", h.GetStats)
-	rg.GET("/historical-inr/:userId", h.GetHistoricalINR)
-	rg.GET("/portfolio/:userId", h.GetPortfolio)
+	self := auth.RequireSelf("userId")
+	api.GET("/today-stocks/:userId", self, h.GetTodayStocks)
+	api.GET("/rewards/:userId", self, h.GetRewards)
+	api.GET("/stats/:userId", self, h.GetStats)
+	api.GET("/historical-inr/:userId", self, h.GetHistoricalINR)
+	api.GET("/performance/:userId", self, h.GetPerformance)
+	api.PUT("/users/:userId/timezone", self, h.SetTimezone)
+	api.GET("/portfolio/:userId", self, h.GetPortfolio)
+	api.GET("/transfers/:userId", self, h.GetTransfers)
+	api.POST("/withdrawals/:userId", auth.RequireRole(auth.RoleUser, auth.RoleAdmin, auth.RoleService), self, h.RequestWithdrawal)
+	api.GET("/withdrawals/:userId", self, h.GetUserWithdrawals)
+	api.GET("/wallet/:userId", self, h.GetWallet)
+	api.GET("/tax/:userId/capital-gains", self, h.GetCapitalGains)
+	api.GET("/ws/:userId", self, h.Stream)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"stocky/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	RoleUser    = "user"
+	RoleAdmin   = "admin"
+	RoleService = "service"
+	RolePartner = "partner"
+
+	APIKeyHeader = "X-API-Key"
+
+	principalKey = "auth.principal"
+)
+
+type Claims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// Principal is the authenticated caller of a request. APIKey is set for
+// partners authenticated with an API key.
+type Principal struct {
+	Subject string           `json:"subject"`
+	Role    string           `json:"role"`
+	APIKey  *database.APIKey `json:"-"`
+}
+
+func (p Principal) HasRole(roles ...string) bool {
+	for _, r := range roles {
+		if p.Role == r {
+			return true
+		}
+	}
+	return false
+}
+
+type Config struct {
+	Algorithm string
+	Secret    []byte
+	PublicKey *rsa.PublicKey
+	Issuer    string
+	Audience  string
+}
+
+// ConfigFromEnv reads JWT_ALG (HS256 or RS256), JWT_SECRET for HS256,
+// JWT_PUBLIC_KEY or JWT_PUBLIC_KEY_FILE (PEM) for RS256, and the optional
+// JWT_ISSUER and JWT_AUDIENCE.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Algorithm: strings.ToUpper(os.Getenv("JWT_ALG")),
+		Issuer:    os.Getenv("JWT_ISSUER"),
+		Audience:  os.Getenv("JWT_AUDIENCE"),
+	}
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = "HS256"
+	}
+	switch cfg.Algorithm {
+	case "HS256":
+		secret := os.Getenv("JWT_SECRET")
+		if secret == "" {
+			return cfg, errors.New("JWT_SECRET is required for HS256")
+		}
+		cfg.Secret = []byte(secret)
+	case "RS256":
+		pemData := []byte(os.Getenv("JWT_PUBLIC_KEY"))
+		if len(pemData) == 0 {
+			path := os.Getenv("JWT_PUBLIC_KEY_FILE")
+			if path == "" {
+				return cfg, errors.New("JWT_PUBLIC_KEY or JWT_PUBLIC_KEY_FILE is required for RS256")
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return cfg, err
+			}
+			pemData = b
+		}
+		key, err := jwt.ParseRSAPublicKeyFromPEM(pemData)
+		if err != nil {
+			return cfg, fmt.Errorf("parse JWT public key: %w", err)
+		}
+		cfg.PublicKey = key
+	default:
+		return cfg, fmt.Errorf("unsupported JWT_ALG %q", cfg.Algorithm)
+	}
+	return cfg, nil
+}
+
+type APIKeyStore interface {
+	GetActiveAPIKeyByHash(ctx context.Context, keyHash string) (database.APIKey, error)
+}
+
+type Authenticator struct {
+	cfg    Config
+	parser *jwt.Parser
+	keys   APIKeyStore
+}
+
+type Option func(*Authenticator)
+
+// WithAPIKeys lets partners authenticate with the X-API-Key header.
+func WithAPIKeys(s APIKeyStore) Option {
+	return func(a *Authenticator) { a.keys = s }
+}
+
+// HashAPIKey is the form in which API keys are stored and looked up.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func New(cfg Config, opts ...Option) *Authenticator {
+	parserOpts := []jwt.ParserOption{jwt.WithValidMethods([]string{cfg.Algorithm}), jwt.WithExpirationRequired()}
+	if cfg.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(cfg.Audience))
+	}
+	a := &Authenticator{cfg: cfg, parser: jwt.NewParser(parserOpts...)}
+	for _, o := range opts {
+		o(a)
+	}
+	return a
+}
+
+func (a *Authenticator) key(*jwt.Token) (interface{}, error) {
+	if a.cfg.Algorithm == "RS256" {
+		return a.cfg.PublicKey, nil
+	}
+	return a.cfg.Secret, nil
+}
+
+// Parse validates a token and returns its principal.
+func (a *Authenticator) Parse(token string) (Principal, error) {
+	var claims Claims
+	if _, err := a.parser.ParseWithClaims(token, &claims, a.key); err != nil {
+		return Principal{}, err
+	}
+	if claims.Subject == "" {
+		return Principal{}, errors.New("token has no subject")
+	}
+	switch claims.Role {
+	case RoleUser, RoleAdmin, RoleService:
+	default:
+		return Principal{}, fmt.Errorf("unknown role %q", claims.Role)
+	}
+	return Principal{Subject: claims.Subject, Role: claims.Role}, nil
+}
+
+// tokenFromRequest reads a bearer token. Browsers cannot set headers on
+// WebSocket handshakes, so those may pass it as ?access_token= instead.
+func tokenFromRequest(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+	}
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return r.URL.Query().Get("access_token")
+	}
+	return ""
+}
+
+func (a *Authenticator) parseAPIKey(ctx context.Context, key string) (Principal, error) {
+	if a.keys == nil {
+		return Principal{}, errors.New("api keys are not enabled")
+	}
+	k, err := a.keys.GetActiveAPIKeyByHash(ctx, HashAPIKey(key))
+	if err != nil {
+		return Principal{}, err
+	}
+	return Principal{Subject: k.Partner, Role: RolePartner, APIKey: &k}, nil
+}
+
+// Middleware rejects requests without a valid token or API key and stores
+// the principal on the context.
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := c.GetHeader(APIKeyHeader); key != "" {
+			p, err := a.parseAPIKey(c.Request.Context(), key)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
+				return
+			}
+			c.Set(principalKey, p)
+			c.Next()
+			return
+		}
+		token := tokenFromRequest(c.Request)
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing bearer token"})
+			return
+		}
+		p, err := a.Parse(token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+		c.Set(principalKey, p)
+		c.Next()
+	}
+}
+
+func FromContext(c *gin.Context) (Principal, bool) {
+	v, ok := c.Get(principalKey)
+	if !ok {
+		return Principal{}, false
+	}
+	p, ok := v.(Principal)
+	return p, ok
+}
+
+// RequireRole allows only principals holding one of roles.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := FromContext(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+			return
+		}
+		if !p.HasRole(roles...) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSelf allows users to access only the resource whose path parameter
+// param equals their subject; admin and service principals may access any.
+func RequireSelf(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := FromContext(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+			return
+		}
+		if p.HasRole(RoleAdmin, RoleService) || (p.Role == RoleUser && p.Subject == c.Param(param)) {
+			c.Next()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+	}
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, sub, role string, ttl time.Duration) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(method, Claims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   sub,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		},
+	})
+	s, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return s
+}
+
+func router(a *Authenticator) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(a.Middleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/portfolio/:userId", RequireSelf("userId"), ok)
+	r.POST("/reward", RequireRole(RoleAdmin, RoleService), ok)
+	return r
+}
+
+func do(r *gin.Engine, method, path, token string) int {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestHS256Roles(t *testing.T) {
+	secret := []byte("test-secret")
+	r := router(New(Config{Algorithm: "HS256", Secret: secret}))
+
+	alice := sign(t, jwt.SigningMethodHS256, secret, "alice", RoleUser, time.Hour)
+	admin := sign(t, jwt.SigningMethodHS256, secret, "ops", RoleAdmin, time.Hour)
+	svc := sign(t, jwt.SigningMethodHS256, secret, "rewards-worker", RoleService, time.Hour)
+	expired := sign(t, jwt.SigningMethodHS256, secret, "alice", RoleUser, -time.Minute)
+	forged := sign(t, jwt.SigningMethodHS256, []byte("other"), "alice", RoleAdmin, time.Hour)
+
+	cases := []struct {
+		name, method, path, token string
+		want                      int
+	}{
+		{"no token", "GET", "/portfolio/alice", "", http.StatusUnauthorized},
+		{"own portfolio", "GET", "/portfolio/alice", alice, http.StatusOK},
+		{"other portfolio", "GET", "/portfolio/bob", alice, http.StatusForbidden},
+		{"admin reads any", "GET", "/portfolio/bob", admin, http.StatusOK},
+		{"user cannot reward", "POST", "/reward", alice, http.StatusForbidden},
+		{"admin rewards", "POST", "/reward", admin, http.StatusOK},
+		{"service rewards", "POST", "/reward", svc, http.StatusOK},
+		{"expired", "GET", "/portfolio/alice", expired, http.StatusUnauthorized},
+		{"wrong key", "POST", "/reward", forged, http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		if got := do(r, tc.method, tc.path, tc.token); got != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRS256RejectsHS256Tokens(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := router(New(Config{Algorithm: "RS256", PublicKey: &key.PublicKey}))
+
+	good := sign(t, jwt.SigningMethodRS256, key, "ops", RoleAdmin, time.Hour)
+	if got := do(r, "POST", "/reward", good); got != http.StatusOK {
+		t.Fatalf("RS256 token: got %d", got)
+	}
+	hs := sign(t, jwt.SigningMethodHS256, []byte("x"), "ops", RoleAdmin, time.Hour)
+	if got := do(r, "POST", "/reward", hs); got != http.StatusUnauthorized {
+		t.Fatalf("HS256 token against RS256 config: got %d", got)
+	}
+}
+
+func TestUnknownRoleRejected(t *testing.T) {
+	secret := []byte("s")
+	a := New(Config{Algorithm: "HS256", Secret: secret})
+	if _, err := a.Parse(sign(t, jwt.SigningMethodHS256, secret, "x", "superuser", time.Hour)); err == nil {
+		t.Fatal("expected unknown role to be rejected")
+	}
+}
@@ -0,0 +1,176 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// IST has no daylight saving, so a fixed zone avoids depending on tzdata.
+var IST = time.FixedZone("IST", 5*3600+30*60)
+
+// Calendar describes the trading sessions of a single exchange: one regular
+// session per weekday, closed on weekends and listed holidays.
+type Calendar struct {
+	loc      *time.Location
+	open     time.Duration
+	close    time.Duration
+	holidays map[string]string
+}
+
+// NSE returns the National Stock Exchange equity calendar (09:15-15:30 IST).
+func NSE(holidays map[string]string) *Calendar {
+	if holidays == nil {
+		holidays = map[string]string{}
+	}
+	return &Calendar{
+		loc:      IST,
+		open:     9*time.Hour + 15*time.Minute,
+		close:    15*time.Hour + 30*time.Minute,
+		holidays: holidays,
+	}
+}
+
+// LoadHolidays reads "YYYY-MM-DD,Name" lines; blank lines and lines starting
+// with # are ignored.
+func LoadHolidays(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	res := map[string]string{}
+	sc := bufio.NewScanner(f)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		parts := strings.SplitN(text, ",", 2)
+		date := strings.TrimSpace(parts[0])
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid date %q", path, line, date)
+		}
+		name := ""
+		if len(parts) == 2 {
+			name = strings.TrimSpace(parts[1])
+		}
+		res[date] = name
+	}
+	return res, sc.Err()
+}
+
+func (c *Calendar) Location() *time.Location {
+	return c.loc
+}
+
+// Holiday returns the holiday name for the exchange-local date of t.
+func (c *Calendar) Holiday(t time.Time) (string, bool) {
+	name, ok := c.holidays[t.In(c.loc).Format(dateLayout)]
+	return name, ok
+}
+
+func (c *Calendar) IsTradingDay(t time.Time) bool {
+	local := t.In(c.loc)
+	if wd := local.Weekday(); wd == time.Saturday || wd == time.Sunday {
+		return false
+	}
+	_, holiday := c.Holiday(local)
+	return !holiday
+}
+
+func (c *Calendar) midnight(t time.Time) time.Time {
+	local := t.In(c.loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.loc)
+}
+
+func (c *Calendar) sessionOpen(t time.Time) time.Time  { return c.midnight(t).Add(c.open) }
+func (c *Calendar) sessionClose(t time.Time) time.Time { return c.midnight(t).Add(c.close) }
+
+func (c *Calendar) IsOpen(t time.Time) bool {
+	if !c.IsTradingDay(t) {
+		return false
+	}
+	return !t.Before(c.sessionOpen(t)) && t.Before(c.sessionClose(t))
+}
+
+// NextOpen returns the start of the next session strictly after t.
+func (c *Calendar) NextOpen(t time.Time) time.Time {
+	day := c.midnight(t)
+	for i := 0; i < 366; i++ {
+		d := day.AddDate(0, 0, i)
+		if c.IsTradingDay(d) {
+			if open := d.Add(c.open); open.After(t) {
+				return open
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// NextClose returns the end of the current session if the market is open,
+// otherwise the end of the next session.
+func (c *Calendar) NextClose(t time.Time) time.Time {
+	if c.IsOpen(t) {
+		return c.sessionClose(t)
+	}
+	open := c.NextOpen(t)
+	if open.IsZero() {
+		return open
+	}
+	return c.sessionClose(open)
+}
+
+// LastClose returns the close of the most recent session that ended at or
+// before t.
+func (c *Calendar) LastClose(t time.Time) time.Time {
+	day := c.midnight(t)
+	for i := 0; i < 366; i++ {
+		d := day.AddDate(0, 0, -i)
+		if c.IsTradingDay(d) {
+			if cl := d.Add(c.close); !cl.After(t) {
+				return cl
+			}
+		}
+	}
+	return time.Time{}
+}
+
+type Status struct {
+	Open      bool      `json:"open"`
+	Now       time.Time `json:"now"`
+	Reason    string    `json:"reason,omitempty"`
+	NextOpen  time.Time `json:"next_open"`
+	NextClose time.Time `json:"next_close"`
+	LastClose time.Time `json:"last_close"`
+}
+
+func (c *Calendar) Status(t time.Time) Status {
+	local := t.In(c.loc)
+	s := Status{
+		Open:      c.IsOpen(local),
+		Now:       local,
+		NextOpen:  c.NextOpen(local),
+		NextClose: c.NextClose(local),
+		LastClose: c.LastClose(local),
+	}
+	if !s.Open {
+		switch {
+		case local.Weekday() == time.Saturday || local.Weekday() == time.Sunday:
+			s.Reason = "weekend"
+		case c.IsTradingDay(local):
+			s.Reason = "outside trading hours"
+		default:
+			name, _ := c.Holiday(local)
+			s.Reason = "holiday: " + name
+		}
+	}
+	return s
+}
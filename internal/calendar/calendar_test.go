@@ -0,0 +1,112 @@
+package calendar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func ist(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, IST)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestIsOpen(t *testing.T) {
+	c := NSE(map[string]string{"2025-10-02": "Gandhi Jayanti"})
+	cases := []struct {
+		at   string
+		open bool
+	}{
+		{"2025-10-01 09:14", false},
+		{"2025-10-01 09:15", true},
+		{"2025-10-01 15:29", true},
+		{"2025-10-01 15:30", false},
+		{"2025-10-02 11:00", false}, // holiday
+		{"2025-10-04 11:00", false}, // Saturday
+		{"2025-10-05 11:00", false}, // Sunday
+	}
+	for _, tc := range cases {
+		if got := c.IsOpen(ist(tc.at)); got != tc.open {
+			t.Errorf("IsOpen(%s) = %v, want %v", tc.at, got, tc.open)
+		}
+	}
+	// 04:00 UTC is 09:30 IST.
+	if !c.IsOpen(time.Date(2025, 10, 1, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected market open at 04:00 UTC")
+	}
+}
+
+func TestNextOpenAndLastClose(t *testing.T) {
+	c := NSE(map[string]string{"2025-10-02": "Gandhi Jayanti"})
+
+	// Wednesday after close -> Friday open, skipping Thursday's holiday.
+	if got, want := c.NextOpen(ist("2025-10-01 16:00")), ist("2025-10-03 09:15"); !got.Equal(want) {
+		t.Errorf("NextOpen = %v, want %v", got, want)
+	}
+	// Sunday -> Friday's close.
+	if got, want := c.LastClose(ist("2025-10-05 12:00")), ist("2025-10-03 15:30"); !got.Equal(want) {
+		t.Errorf("LastClose = %v, want %v", got, want)
+	}
+	// During a session the last close is the previous trading day's.
+	if got, want := c.LastClose(ist("2025-10-03 10:00")), ist("2025-10-01 15:30"); !got.Equal(want) {
+		t.Errorf("LastClose = %v, want %v", got, want)
+	}
+	if got, want := c.NextClose(ist("2025-10-03 10:00")), ist("2025-10-03 15:30"); !got.Equal(want) {
+		t.Errorf("NextClose = %v, want %v", got, want)
+	}
+}
+
+func TestStatusReason(t *testing.T) {
+	c := NSE(map[string]string{"2025-10-02": "Gandhi Jayanti"})
+	if s := c.Status(ist("2025-10-02 11:00")); s.Open || s.Reason != "holiday: Gandhi Jayanti" {
+		t.Errorf("unexpected status %+v", s)
+	}
+	if s := c.Status(ist("2025-10-04 11:00")); s.Reason != "weekend" {
+		t.Errorf("unexpected status %+v", s)
+	}
+}
+
+func TestLoadHolidays(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "h.csv")
+	content := "# comment\n\n2025-12-25,Christmas\n2025-08-15, Independence Day\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h, err := LoadHolidays(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(h) != 2 || h["2025-08-15"] != "Independence Day" {
+		t.Fatalf("unexpected holidays %v", h)
+	}
+
+	if err := os.WriteFile(path, []byte("25-12-2025,Bad\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadHolidays(path); err == nil {
+		t.Fatalf("expected error for malformed date")
+	}
+}
+
+func TestShippedHolidays(t *testing.T) {
+	h, err := LoadHolidays("../../data/nse_holidays.csv")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	c := NSE(h)
+	for _, d := range []string{"2026-03-03", "2026-04-03", "2026-09-14", "2026-10-20", "2026-11-10", "2026-11-24"} {
+		if c.IsOpen(ist(d + " 11:00")) {
+			t.Errorf("%s should be a holiday", d)
+		}
+	}
+	for date := range h {
+		d, _ := time.Parse("2006-01-02", date)
+		if wd := d.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			t.Errorf("%s falls on a weekend", date)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package calendar
+
+import "time"
+
+// DefaultTimezone is the zone user-facing days follow unless configured
+// otherwise.
+const DefaultTimezone = "Asia/Kolkata"
+
+// LoadLocation is time.LoadLocation, except that India resolves to IST even
+// where no tzdata is installed.
+func LoadLocation(name string) (*time.Location, error) {
+	switch name {
+	case "", DefaultTimezone, "Asia/Calcutta", "IST":
+		return IST, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// DayStart returns midnight at the start of t's day in loc.
+func DayStart(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// Date returns the calendar date of t in loc as YYYY-MM-DD.
+func Date(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format(dateLayout)
+}
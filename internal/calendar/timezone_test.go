@@ -0,0 +1,43 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadLocation(t *testing.T) {
+	for _, name := range []string{"", DefaultTimezone, "Asia/Calcutta"} {
+		loc, err := LoadLocation(name)
+		if err != nil || loc != IST {
+			t.Errorf("LoadLocation(%q) = %v, %v; want IST", name, loc, err)
+		}
+	}
+	if _, err := LoadLocation("Not/AZone"); err == nil {
+		t.Error("unknown zone should fail")
+	}
+	if loc, err := LoadLocation("UTC"); err != nil || loc != time.UTC {
+		t.Errorf("LoadLocation(UTC) = %v, %v", loc, err)
+	}
+}
+
+func TestDayAcrossISTUTCBoundary(t *testing.T) {
+	// 02:00 IST on 10 March is still 9 March in UTC.
+	at := time.Date(2025, 3, 9, 20, 30, 0, 0, time.UTC)
+	if got := Date(at, IST); got != "2025-03-10" {
+		t.Errorf("IST date = %s, want 2025-03-10", got)
+	}
+	if got := Date(at, time.UTC); got != "2025-03-09" {
+		t.Errorf("UTC date = %s, want 2025-03-09", got)
+	}
+	start := DayStart(at, IST)
+	if want := time.Date(2025, 3, 9, 18, 30, 0, 0, time.UTC); !start.Equal(want) {
+		t.Errorf("IST day start = %v, want %v", start.UTC(), want)
+	}
+	if want := time.Date(2025, 3, 9, 0, 0, 0, 0, time.UTC); !DayStart(at, time.UTC).Equal(want) {
+		t.Errorf("UTC day start = %v, want %v", DayStart(at, time.UTC), want)
+	}
+	// 23:00 IST on 10 March is 17:30 UTC, the same day in both zones.
+	if got := Date(time.Date(2025, 3, 10, 17, 30, 0, 0, time.UTC), IST); got != "2025-03-10" {
+		t.Errorf("late IST date = %s", got)
+	}
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var ErrQuotaExceeded = errors.New("daily quota exceeded")
+
+type APIKey struct {
+	ID             string         `db:"id" json:"id"`
+	Partner        string         `db:"partner" json:"partner"`
+	KeyPrefix      string         `db:"key_prefix" json:"key_prefix"`
+	KeyHash        string         `db:"key_hash" json:"-"`
+	AllowedSources pq.StringArray `db:"allowed_sources" json:"allowed_sources"`
+	AllowedSymbols pq.StringArray `db:"allowed_symbols" json:"allowed_symbols"`
+	DailyQuota     int            `db:"daily_quota" json:"daily_quota"`
+	RotatedFrom    *string        `db:"rotated_from" json:"rotated_from,omitempty"`
+	CreatedAt      time.Time      `db:"created_at" json:"created_at"`
+	LastUsedAt     *time.Time     `db:"last_used_at" json:"last_used_at,omitempty"`
+	RevokedAt      *time.Time     `db:"revoked_at" json:"revoked_at,omitempty"`
+}
+
+const apiKeyColumns = `id, partner, key_prefix, key_hash, allowed_sources, allowed_symbols, daily_quota, rotated_from, created_at, last_used_at, revoked_at`
+
+// stringArray keeps an omitted list as an empty array; a nil
+// pq.StringArray is sent as NULL, which the NOT NULL columns reject.
+func stringArray(s []string) pq.StringArray {
+	if s == nil {
+		return pq.StringArray{}
+	}
+	return pq.StringArray(s)
+}
+
+func (r *Repo) CreateAPIKey(ctx context.Context, k APIKey) (APIKey, error) {
+	var res APIKey
+	err := r.db.GetContext(ctx, &res, `INSERT INTO api_keys (partner, key_prefix, key_hash, allowed_sources, allowed_symbols, daily_quota, rotated_from) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING `+apiKeyColumns,
+		k.Partner, k.KeyPrefix, k.KeyHash, stringArray(k.AllowedSources), stringArray(k.AllowedSymbols), k.DailyQuota, k.RotatedFrom)
+	return res, err
+}
+
+// GetActiveAPIKeyByHash returns the unrevoked key with the given hash and
+// records it as used.
+func (r *Repo) GetActiveAPIKeyByHash(ctx context.Context, keyHash string) (APIKey, error) {
+	var res APIKey
+	err := r.db.GetContext(ctx, &res, `UPDATE api_keys SET last_used_at = now() WHERE key_hash = $1 AND revoked_at IS NULL RETURNING `+apiKeyColumns, keyHash)
+	return res, err
+}
+
+func (r *Repo) GetAPIKey(ctx context.Context, id string) (APIKey, error) {
+	var res APIKey
+	if !ValidUUID(id) {
+		return res, sql.ErrNoRows
+	}
+	err := r.db.GetContext(ctx, &res, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1`, id)
+	return res, err
+}
+
+func (r *Repo) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	res := []APIKey{}
+	err := r.db.SelectContext(ctx, &res, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at DESC`)
+	return res, err
+}
+
+// RevokeAPIKey returns sql.ErrNoRows for unknown, malformed or already
+// revoked ids.
+func (r *Repo) RevokeAPIKey(ctx context.Context, id string) error {
+	if !ValidUUID(id) {
+		return sql.ErrNoRows
+	}
+	res, err := r.db.ExecContext(ctx, `UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RotateAPIKey revokes the key id and issues its replacement with the same
+// permissions in one transaction. It returns sql.ErrNoRows for unknown,
+// malformed or already revoked ids.
+func (r *Repo) RotateAPIKey(ctx context.Context, id, newPrefix, newHash string) (APIKey, error) {
+	if !ValidUUID(id) {
+		return APIKey{}, sql.ErrNoRows
+	}
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return APIKey{}, err
+	}
+	defer tx.Rollback()
+
+	var old APIKey
+	if err := tx.GetContext(ctx, &old, `UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL RETURNING `+apiKeyColumns, id); err != nil {
+		return APIKey{}, err
+	}
+	var res APIKey
+	if err := tx.GetContext(ctx, &res, `INSERT INTO api_keys (partner, key_prefix, key_hash, allowed_sources, allowed_symbols, daily_quota, rotated_from) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING `+apiKeyColumns,
+		old.Partner, newPrefix, newHash, old.AllowedSources, old.AllowedSymbols, old.DailyQuota, old.ID); err != nil {
+		return APIKey{}, err
+	}
+	return res, tx.Commit()
+}
+
+// consumeAPIKeyQuota counts one use of the key for the calendar date of day,
+// in day's location, inside tx and returns ErrQuotaExceeded once quota uses
+// have already been recorded. A quota of zero is unlimited.
+func consumeAPIKeyQuota(ctx context.Context, tx *sqlx.Tx, id string, day time.Time, quota int) error {
+	if quota <= 0 {
+		return nil
+	}
+	var count int
+	err := tx.GetContext(ctx, &count, `
+		INSERT INTO api_key_usage (api_key_id, day, count) VALUES ($1, $2, 1)
+		ON CONFLICT (api_key_id, day) DO UPDATE SET count = api_key_usage.count + 1
+		WHERE api_key_usage.count < $3
+		RETURNING count`, id, day.Format("2006-01-02"), quota)
+	if err == sql.ErrNoRows {
+		return ErrQuotaExceeded
+	}
+	return err
+}
+
+// AllowsSource reports whether rewards from this key may carry source. Keys
+// without an explicit list may only use their partner name.
+func (k APIKey) AllowsSource(source string) bool {
+	if len(k.AllowedSources) == 0 {
+		return source == k.Partner
+	}
+	for _, s := range k.AllowedSources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsSymbol reports whether the key may grant symbol; an empty list
+// allows every symbol.
+func (k APIKey) AllowsSymbol(symbol string) bool {
+	if len(k.AllowedSymbols) == 0 {
+		return true
+	}
+	for _, s := range k.AllowedSymbols {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"stocky/internal/policy"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+func TestAPIKeyAllows(t *testing.T) {
+	k := APIKey{Partner: "acme"}
+	if !k.AllowsSource("acme") || k.AllowsSource("other") || k.AllowsSource("") {
+		t.Fatalf("key without sources must only allow its partner name")
+	}
+	if !k.AllowsSymbol("TCS") {
+		t.Fatalf("key without symbols must allow any symbol")
+	}
+
+	k.AllowedSources = []string{"acme-referral", "acme-onboarding"}
+	k.AllowedSymbols = []string{"INFY"}
+	if !k.AllowsSource("acme-referral") || k.AllowsSource("acme") {
+		t.Fatalf("explicit sources must be matched exactly")
+	}
+	if !k.AllowsSymbol("INFY") || k.AllowsSymbol("TCS") {
+		t.Fatalf("explicit symbols must be matched exactly")
+	}
+}
+
+func TestAPIKeyUnrestrictedAndQuota(t *testing.T) {
+	db := setupDB(t)
+	r := New(db, logrus.New())
+	ctx := context.Background()
+
+	stamp := time.Now().Format("20060102150405.000000")
+	k, err := r.CreateAPIKey(ctx, APIKey{Partner: "test-partner", KeyPrefix: "test", KeyHash: "test-hash-" + stamp, DailyQuota: 1})
+	if err != nil {
+		t.Fatalf("create unrestricted key failed: %v", err)
+	}
+	if k.AllowedSources == nil || len(k.AllowedSources) != 0 || len(k.AllowedSymbols) != 0 {
+		t.Fatalf("expected empty restriction lists, got %+v", k)
+	}
+
+	user := "test-apikey-user"
+	if _, err := db.Exec("INSERT INTO users (id, name) VALUES ($1, $1) ON CONFLICT (id) DO NOTHING", user); err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	in := func(i int, limits *policy.Limits) RewardInput {
+		return RewardInput{UserID: user, Symbol: "TCS", Quantity: decimal.NewFromInt(1), Timestamp: time.Now().UTC(),
+			IdempotencyKey: fmt.Sprintf("test-apikey-%s-%d", stamp, i), Source: "test-partner", Price: decimal.NewFromInt(100),
+			Limits: limits, APIKeyID: k.ID, DailyQuota: k.DailyQuota}
+	}
+
+	// A rejected reward must not use up the quota.
+	tight := policy.Limits{MaxRewardINR: decimal.NewFromInt(10)}
+	if _, _, err := r.CreateReward(ctx, in(0, &tight)); err == nil {
+		t.Fatal("expected the reward limit to reject the first reward")
+	}
+	if _, created, err := r.CreateReward(ctx, in(1, nil)); err != nil || !created {
+		t.Fatalf("expected the quota to allow one reward, got created=%v err=%v", created, err)
+	}
+	// Replays return the booked reward without charging the quota.
+	if _, created, err := r.CreateReward(ctx, in(1, nil)); err != nil || created {
+		t.Fatalf("expected a replay, got created=%v err=%v", created, err)
+	}
+	if _, _, err := r.CreateReward(ctx, in(2, nil)); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+
+	// Malformed ids read as unknown keys rather than failing the query.
+	if err := r.RevokeAPIKey(ctx, "not-a-uuid"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows revoking a malformed id, got %v", err)
+	}
+	if _, err := r.RotateAPIKey(ctx, "not-a-uuid", "test", "test-hash-rotated-"+stamp); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows rotating a malformed id, got %v", err)
+	}
+}
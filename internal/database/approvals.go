@@ -0,0 +1,232 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"stocky/internal/events"
+	"stocky/internal/vesting"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrNotPending   = errors.New("reward is not pending approval")
+	ErrSelfApproval = errors.New("a reward cannot be decided by the admin who requested it")
+)
+
+type bookedReward struct {
+	ID        string
+	UserID    string
+	Symbol    string
+	Quantity  decimal.Decimal
+	Price     decimal.Decimal
+	Timestamp time.Time
+	Source    string
+	Vesting   *vesting.Schedule
+	AmountINR *decimal.Decimal
+}
+
+// creditReward posts the purchase ledger entries for a booked reward, adds
+// the shares to the user's holdings, or starts vesting them, and queues the
+// reward.created event.
+func creditReward(ctx context.Context, tx *sqlx.Tx, b bookedReward) error {
+	amountINR := b.Quantity.Mul(b.Price)
+	fees := amountINR.Mul(decimal.NewFromFloat(0.01)).Round(4)
+	totalCashOut := amountINR.Add(fees)
+
+	ledgerQ := `INSERT INTO ledger_entries (id, reward_id, entry_time, account_debit, account_credit, amount_inr, stock_symbol, stock_quantity, description) VALUES (gen_random_uuid(), $1, now(), $2, $3, $4::numeric, $5, $6::numeric, $7)`
+	if _, err := tx.ExecContext(ctx, ledgerQ, b.ID, "company_cash", "stock_inventory", totalCashOut.StringFixed(4), b.Symbol, b.Quantity.StringFixed(6), "reward purchase"); err != nil {
+		return err
+	}
+	if fees.Cmp(decimal.Zero) > 0 {
+		if _, err := tx.ExecContext(ctx, ledgerQ, b.ID, "company_expense", "company_cash", fees.StringFixed(4), nil, nil, "fees for reward"); err != nil {
+			return err
+		}
+	}
+	if b.AmountINR != nil {
+		// Rounding down leaves part of the requested amount unspent; rounding
+		// up spends more than was requested.
+		residue := b.AmountINR.Sub(amountINR).Round(4)
+		debit, credit := "rounding_residue", "company_cash"
+		if residue.IsNegative() {
+			debit, credit = "company_cash", "rounding_residue"
+		}
+		if !residue.IsZero() {
+			if _, err := tx.ExecContext(ctx, ledgerQ, b.ID, debit, credit, residue.Abs().StringFixed(4), nil, nil, "rounding residue"); err != nil {
+				return err
+			}
+		}
+	}
+
+	if b.Vesting != nil {
+		if err := startVesting(ctx, tx, b); err != nil {
+			return err
+		}
+	} else {
+		upsert := `INSERT INTO holdings (user_id, symbol, quantity, last_updated) VALUES ($1, $2, $3::numeric, now()) ON CONFLICT (user_id, symbol) DO UPDATE SET quantity = holdings.quantity + $3::numeric, last_updated = now()`
+		if _, err := tx.ExecContext(ctx, upsert, b.UserID, b.Symbol, b.Quantity.String()); err != nil {
+			return err
+		}
+		if err := addLot(ctx, tx, b.UserID, b.Symbol, b.Quantity, b.Price, b.Timestamp, LotReward, &b.ID); err != nil {
+			return err
+		}
+	}
+
+	return enqueueOutbox(ctx, tx, events.RewardCreated, map[string]interface{}{
+		"reward_id": b.ID,
+		"user_id":   b.UserID,
+		"symbol":    b.Symbol,
+		"quantity":  b.Quantity.StringFixed(6),
+		"price_inr": b.Price.StringFixed(4),
+		"timestamp": b.Timestamp,
+		"source":    b.Source,
+		"vesting":   b.Vesting,
+	})
+}
+
+type Approval struct {
+	ID          string          `db:"id" json:"id"`
+	RewardID    string          `db:"reward_id" json:"reward_id"`
+	UserID      string          `db:"user_id" json:"user_id"`
+	Symbol      string          `db:"symbol" json:"symbol"`
+	Quantity    decimal.Decimal `db:"quantity" json:"quantity"`
+	PriceINR    decimal.Decimal `db:"price_inr" json:"price_inr"`
+	ValueINR    decimal.Decimal `db:"value_inr" json:"value_inr"`
+	Source      *string         `db:"source" json:"source,omitempty"`
+	Status      string          `db:"status" json:"status"`
+	RequestedBy string          `db:"requested_by" json:"requested_by"`
+	DecidedBy   *string         `db:"decided_by" json:"decided_by,omitempty"`
+	Note        *string         `db:"note" json:"note,omitempty"`
+	CreatedAt   time.Time       `db:"created_at" json:"created_at"`
+	DecidedAt   *time.Time      `db:"decided_at" json:"decided_at,omitempty"`
+}
+
+func (r *Repo) ListApprovals(ctx context.Context, status string) ([]Approval, error) {
+	res := []Approval{}
+	err := r.db.SelectContext(ctx, &res, `
+		SELECT a.id, a.reward_id, rw.user_id, rw.symbol, rw.quantity, rw.price_inr, rw.quantity * rw.price_inr AS value_inr,
+			rw.source, a.status, a.requested_by, a.decided_by, a.note, a.created_at, a.decided_at
+		FROM approvals a
+		JOIN rewards rw ON rw.id = a.reward_id
+		WHERE ($1 = '' OR a.status = $1)
+		ORDER BY a.created_at ASC`, status)
+	return res, err
+}
+
+// lockPendingApproval locks a pending reward and its approval row and checks
+// that decidedBy is not the admin who requested it.
+func lockPendingApproval(ctx context.Context, tx *sqlx.Tx, rewardID, decidedBy string) (bookedReward, error) {
+	var b bookedReward
+	var status, requestedBy string
+	var source *string
+	var cliff, months *int
+	var amount decimal.NullDecimal
+	err := tx.QueryRowContext(ctx, `
+		SELECT rw.status, rw.user_id, rw.symbol, rw.quantity, rw.price_inr, rw.timestamp, rw.source, a.requested_by,
+			rw.vesting_cliff_months, rw.vesting_months, rw.requested_amount_inr
+		FROM rewards rw
+		JOIN approvals a ON a.reward_id = rw.id
+		WHERE rw.id = $1
+		FOR UPDATE`, rewardID).Scan(&status, &b.UserID, &b.Symbol, &b.Quantity, &b.Price, &b.Timestamp, &source, &requestedBy, &cliff, &months, &amount)
+	if err != nil {
+		return b, err
+	}
+	if status != StatusPendingApproval {
+		return b, ErrNotPending
+	}
+	if requestedBy == decidedBy {
+		return b, ErrSelfApproval
+	}
+	b.ID = rewardID
+	if source != nil {
+		b.Source = *source
+	}
+	if amount.Valid {
+		b.AmountINR = &amount.Decimal
+	}
+	if cliff != nil && months != nil {
+		b.Vesting = &vesting.Schedule{CliffMonths: *cliff, Months: *months}
+	}
+	return b, nil
+}
+
+// ApproveReward credits a pending reward. The approver must differ from the
+// admin who requested the reward.
+func (r *Repo) ApproveReward(ctx context.Context, rewardID, approver, note string) (string, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	b, err := lockPendingApproval(ctx, tx, rewardID, approver)
+	if err != nil {
+		return "", err
+	}
+	before, err := rewardSnapshot(ctx, tx, rewardID)
+	if err != nil {
+		return "", err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE rewards SET status = 'COMPLETED' WHERE id = $1`, rewardID); err != nil {
+		return "", err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE approvals SET status = 'APPROVED', decided_by = $2, note = NULLIF($3, ''), decided_at = now() WHERE reward_id = $1`, rewardID, approver, note); err != nil {
+		return "", err
+	}
+	if err := creditReward(ctx, tx, b); err != nil {
+		return "", err
+	}
+	after, err := rewardSnapshot(ctx, tx, rewardID)
+	if err != nil {
+		return "", err
+	}
+	if err := recordAudit(ctx, tx, AuditRewardApprove, "reward", rewardID, before, after, note); err != nil {
+		return "", err
+	}
+	return b.UserID, tx.Commit()
+}
+
+// RejectReward closes a pending reward without touching holdings or the
+// ledger and returns its value to its campaign's budget, if any.
+func (r *Repo) RejectReward(ctx context.Context, rewardID, approver, note string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockPendingApproval(ctx, tx, rewardID, approver); err != nil {
+		return err
+	}
+	if err := rejectReward(ctx, tx, rewardID, approver, note); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// rejectReward marks a pending reward locked in tx rejected by decidedBy and
+// refunds its campaign. It records an audit event, so nothing else should
+// follow it in tx.
+func rejectReward(ctx context.Context, tx *sqlx.Tx, rewardID, decidedBy, note string) error {
+	before, err := rewardSnapshot(ctx, tx, rewardID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE rewards SET status = 'REJECTED' WHERE id = $1`, rewardID); err != nil {
+		return err
+	}
+	if err := refundCampaign(ctx, tx, rewardID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE approvals SET status = 'REJECTED', decided_by = $2, note = NULLIF($3, ''), decided_at = now() WHERE reward_id = $1`, rewardID, decidedBy, note); err != nil {
+		return err
+	}
+	after, err := rewardSnapshot(ctx, tx, rewardID)
+	if err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, AuditRewardReject, "reward", rewardID, before, after, note)
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+func TestApprovalWorkflow(t *testing.T) {
+	db := setupDB(t)
+	r := New(db, logrus.New())
+	ctx := context.Background()
+
+	userID := "test-approval-user"
+	symbol := "TCS"
+	if _, err := db.Exec("INSERT INTO users (id, name) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING", userID, "Test Approval User"); err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	for _, k := range []string{"test-approval-approve", "test-approval-reject"} {
+		_, _ = db.Exec("DELETE FROM approvals WHERE reward_id IN (SELECT id FROM rewards WHERE idempotency_key = $1)", k)
+		_, _ = db.Exec("DELETE FROM ledger_entries WHERE reward_id IN (SELECT id FROM rewards WHERE idempotency_key = $1)", k)
+		_, _ = db.Exec("DELETE FROM rewards WHERE idempotency_key = $1", k)
+	}
+	_, _ = db.Exec("DELETE FROM holdings WHERE user_id = $1", userID)
+
+	q := decimal.NewFromInt(5)
+	in := RewardInput{UserID: userID, Symbol: symbol, Quantity: q, Timestamp: time.Now().UTC(), Source: "test", Price: decimal.NewFromInt(4000), RequiresApproval: true, RequestedBy: "maker"}
+
+	in.IdempotencyKey = "test-approval-approve"
+	approveID, _, err := r.CreateReward(ctx, in)
+	if err != nil {
+		t.Fatalf("create reward failed: %v", err)
+	}
+	in.IdempotencyKey = "test-approval-reject"
+	rejectID, _, err := r.CreateReward(ctx, in)
+	if err != nil {
+		t.Fatalf("create reward failed: %v", err)
+	}
+
+	holdings, err := r.GetHoldings(ctx, userID)
+	if err != nil {
+		t.Fatalf("get holdings failed: %v", err)
+	}
+	if len(holdings) != 0 {
+		t.Fatalf("expected no holdings before approval, got %v", holdings)
+	}
+
+	if _, err := r.ApproveReward(ctx, approveID, "maker", ""); !errors.Is(err, ErrSelfApproval) {
+		t.Fatalf("expected ErrSelfApproval, got %v", err)
+	}
+	if _, err := r.ApproveReward(ctx, approveID, "checker", "ok"); err != nil {
+		t.Fatalf("approve failed: %v", err)
+	}
+	if _, err := r.ApproveReward(ctx, approveID, "checker", ""); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("expected ErrNotPending on second approval, got %v", err)
+	}
+	if err := r.RejectReward(ctx, rejectID, "checker", "too large"); err != nil {
+		t.Fatalf("reject failed: %v", err)
+	}
+
+	holdings, err = r.GetHoldings(ctx, userID)
+	if err != nil {
+		t.Fatalf("get holdings failed: %v", err)
+	}
+	if len(holdings) != 1 || !holdings[0].Quantity.Equal(q) {
+		t.Fatalf("expected holdings %s after approval only, got %v", q, holdings)
+	}
+
+	var entries int
+	if err := db.Get(&entries, "SELECT count(*) FROM ledger_entries WHERE reward_id = $1", rejectID); err != nil {
+		t.Fatalf("count ledger entries failed: %v", err)
+	}
+	if entries != 0 {
+		t.Fatalf("rejected reward has %d ledger entries", entries)
+	}
+}
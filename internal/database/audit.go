@@ -0,0 +1,237 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	AuditRewardCreate  = "reward.create"
+	AuditRewardReverse = "reward.reverse"
+	AuditRewardApprove = "reward.approve"
+	AuditRewardReject  = "reward.reject"
+	AuditStockCreate   = "stock.create"
+	AuditStockUpdate   = "stock.update"
+	AuditStockDelist   = "stock.delist"
+	AuditUserCreate    = "user.create"
+	AuditPriceOverride = "price.override"
+
+	// ActorSystem is recorded for changes made outside a request, such as
+	// seeding stocks at startup.
+	ActorSystem = "system"
+)
+
+// Actor identifies who caused a change and the request it came from. It
+// travels in the context so every audited write can record it without each
+// repo method taking it as an argument.
+type Actor struct {
+	Subject   string
+	Role      string
+	RequestID string
+}
+
+type actorKey struct{}
+
+func WithActor(ctx context.Context, a Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, a)
+}
+
+func actorFrom(ctx context.Context) Actor {
+	a, _ := ctx.Value(actorKey{}).(Actor)
+	if a.Subject == "" {
+		a.Subject = ActorSystem
+	}
+	return a
+}
+
+// AuditEvent is one row of the append-only audit log. Before and After are
+// JSON snapshots of the entity, null when it did not exist.
+type AuditEvent struct {
+	ID         int64           `db:"id" json:"id"`
+	CreatedAt  time.Time       `db:"created_at" json:"created_at"`
+	Actor      string          `db:"actor" json:"actor"`
+	ActorRole  string          `db:"actor_role" json:"actor_role,omitempty"`
+	Action     string          `db:"action" json:"action"`
+	EntityType string          `db:"entity_type" json:"entity_type"`
+	EntityID   string          `db:"entity_id" json:"entity_id"`
+	Before     json.RawMessage `db:"before" json:"before"`
+	After      json.RawMessage `db:"after" json:"after"`
+	Reason     string          `db:"reason" json:"reason,omitempty"`
+	RequestID  string          `db:"request_id" json:"request_id,omitempty"`
+	PrevHash   string          `db:"prev_hash" json:"prev_hash"`
+	Hash       string          `db:"hash" json:"hash"`
+}
+
+const auditColumns = `id, created_at, actor, actor_role, action, entity_type, entity_id, before, after, reason, request_id, prev_hash, hash`
+
+// ComputeHash returns the SHA-256 of the event's fields chained to prevHash.
+// The fields are encoded as a JSON array so no value can be confused with a
+// separator.
+func (e AuditEvent) ComputeHash(prevHash string) string {
+	b, _ := json.Marshal([]string{
+		prevHash,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+		e.Actor,
+		e.ActorRole,
+		e.Action,
+		e.EntityType,
+		e.EntityID,
+		string(e.Before),
+		string(e.After),
+		e.Reason,
+		e.RequestID,
+	})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// snapshot returns the row selected by q as JSON, or null if there is none.
+// q must select a single row_to_json column.
+func snapshot(ctx context.Context, tx *sqlx.Tx, q string, args ...interface{}) (json.RawMessage, error) {
+	var b []byte
+	err := tx.QueryRowContext(ctx, q, args...).Scan(&b)
+	if errors.Is(err, sql.ErrNoRows) {
+		return json.RawMessage("null"), nil
+	}
+	return b, err
+}
+
+func rewardSnapshot(ctx context.Context, tx *sqlx.Tx, rewardID string) (json.RawMessage, error) {
+	return snapshot(ctx, tx, `SELECT row_to_json(t) FROM rewards t WHERE id = $1`, rewardID)
+}
+
+// recordAudit appends an event to the audit log inside tx. It takes the
+// chain lock, so callers make it their last statement before committing to
+// hold the lock as briefly as possible.
+func recordAudit(ctx context.Context, tx *sqlx.Tx, action, entityType, entityID string, before, after json.RawMessage, reason string) error {
+	if before == nil {
+		before = json.RawMessage("null")
+	}
+	if after == nil {
+		after = json.RawMessage("null")
+	}
+	a := actorFrom(ctx)
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('audit-chain'))`); err != nil {
+		return err
+	}
+	var prev string
+	if err := tx.GetContext(ctx, &prev, `SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1`); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	e := AuditEvent{
+		// Postgres keeps microseconds; truncate so the stored time hashes the same.
+		CreatedAt:  time.Now().UTC().Truncate(time.Microsecond),
+		Actor:      a.Subject,
+		ActorRole:  a.Role,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Before:     before,
+		After:      after,
+		Reason:     reason,
+		RequestID:  a.RequestID,
+		PrevHash:   prev,
+	}
+	e.Hash = e.ComputeHash(prev)
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO audit_events (created_at, actor, actor_role, action, entity_type, entity_id, before, after, reason, request_id, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7::json, $8::json, $9, $10, $11, $12)`,
+		e.CreatedAt, e.Actor, e.ActorRole, e.Action, e.EntityType, e.EntityID, string(e.Before), string(e.After), e.Reason, e.RequestID, e.PrevHash, e.Hash)
+	return err
+}
+
+// AuditFilter selects events for ListAuditEvents. Empty fields match
+// everything; From is inclusive and To exclusive. BeforeID pages backwards
+// from an earlier result.
+type AuditFilter struct {
+	EntityType string
+	EntityID   string
+	Actor      string
+	Action     string
+	RequestID  string
+	From       time.Time
+	To         time.Time
+	BeforeID   int64
+	Limit      int
+}
+
+// ListAuditEvents returns up to f.Limit events, newest first, and the id to
+// pass as BeforeID for the next page, or 0 if this is the last one.
+func (r *Repo) ListAuditEvents(ctx context.Context, f AuditFilter) ([]AuditEvent, int64, error) {
+	res := []AuditEvent{}
+	err := r.db.SelectContext(ctx, &res, `SELECT `+auditColumns+` FROM audit_events
+		WHERE ($1 = '' OR entity_type = $1)
+		  AND ($2 = '' OR entity_id = $2)
+		  AND ($3 = '' OR actor = $3)
+		  AND ($4 = '' OR action = $4)
+		  AND ($5 = '' OR request_id = $5)
+		  AND ($6::timestamptz IS NULL OR created_at >= $6)
+		  AND ($7::timestamptz IS NULL OR created_at < $7)
+		  AND ($8 = 0 OR id < $8)
+		ORDER BY id DESC
+		LIMIT $9`,
+		f.EntityType, f.EntityID, f.Actor, f.Action, f.RequestID, nullTime(f.From), nullTime(f.To), f.BeforeID, f.Limit+1)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(res) <= f.Limit {
+		return res, 0, nil
+	}
+	res = res[:f.Limit]
+	return res, res[len(res)-1].ID, nil
+}
+
+// AuditVerification is the result of walking the audit chain. BrokenAt is
+// the first event whose hash or link to its predecessor does not match.
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Checked  int    `json:"checked"`
+	LastHash string `json:"last_hash,omitempty"`
+	BrokenAt *int64 `json:"broken_at,omitempty"`
+	Problem  string `json:"problem,omitempty"`
+}
+
+// VerifyChain checks events in id order, continuing from prevHash. It
+// stops at the first mismatch.
+func VerifyChain(events []AuditEvent, prevHash string, v *AuditVerification) bool {
+	for _, e := range events {
+		switch {
+		case e.PrevHash != prevHash:
+			v.Problem = "prev_hash does not match the previous event"
+		case e.ComputeHash(prevHash) != e.Hash:
+			v.Problem = "hash does not match the event"
+		}
+		if v.Problem != "" {
+			id := e.ID
+			v.Valid, v.BrokenAt = false, &id
+			return false
+		}
+		prevHash = e.Hash
+		v.Checked++
+		v.LastHash = e.Hash
+	}
+	return true
+}
+
+// VerifyAuditChain recomputes every event's hash from the start of the log.
+func (r *Repo) VerifyAuditChain(ctx context.Context) (AuditVerification, error) {
+	v := AuditVerification{Valid: true}
+	var lastID int64
+	for {
+		batch := []AuditEvent{}
+		if err := r.db.SelectContext(ctx, &batch, `SELECT `+auditColumns+` FROM audit_events WHERE id > $1 ORDER BY id LIMIT 1000`, lastID); err != nil {
+			return v, err
+		}
+		if len(batch) == 0 || !VerifyChain(batch, v.LastHash, &v) {
+			return v, nil
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+func TestVerifyChainDetectsTampering(t *testing.T) {
+	base := time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC)
+	events := []AuditEvent{
+		{ID: 1, CreatedAt: base, Actor: "admin-1", Action: AuditRewardCreate, EntityType: "reward", EntityID: "r1", Before: json.RawMessage("null"), After: json.RawMessage(`{"status":"COMPLETED"}`)},
+		{ID: 2, CreatedAt: base.Add(time.Minute), Actor: "admin-2", Action: AuditRewardReverse, EntityType: "reward", EntityID: "r1", Before: json.RawMessage(`{"status":"COMPLETED"}`), After: json.RawMessage(`{"status":"REVERSED"}`), Reason: "duplicate"},
+		{ID: 3, CreatedAt: base.Add(2 * time.Minute), Actor: "system", Action: AuditStockCreate, EntityType: "stock", EntityID: "TCS", Before: json.RawMessage("null"), After: json.RawMessage(`{"symbol":"TCS"}`)},
+	}
+	prev := ""
+	for i := range events {
+		events[i].PrevHash = prev
+		events[i].Hash = events[i].ComputeHash(prev)
+		prev = events[i].Hash
+	}
+
+	var v AuditVerification
+	if !VerifyChain(events, "", &v) || v.Checked != 3 || v.LastHash != events[2].Hash {
+		t.Fatalf("untouched chain should verify, got %+v", v)
+	}
+
+	edited := append([]AuditEvent(nil), events...)
+	edited[1].Reason = "fraud"
+	v = AuditVerification{}
+	if VerifyChain(edited, "", &v) || v.BrokenAt == nil || *v.BrokenAt != 2 {
+		t.Fatalf("editing a field should break the chain at event 2, got %+v", v)
+	}
+
+	rehashed := append([]AuditEvent(nil), edited...)
+	rehashed[1].Hash = rehashed[1].ComputeHash(rehashed[1].PrevHash)
+	v = AuditVerification{}
+	if VerifyChain(rehashed, "", &v) || v.BrokenAt == nil || *v.BrokenAt != 3 {
+		t.Fatalf("rehashing an edited event should break the link from event 3, got %+v", v)
+	}
+
+	deleted := []AuditEvent{events[0], events[2]}
+	v = AuditVerification{}
+	if VerifyChain(deleted, "", &v) || v.BrokenAt == nil || *v.BrokenAt != 3 {
+		t.Fatalf("deleting an event should break the chain, got %+v", v)
+	}
+}
+
+func TestAuditTrailForReward(t *testing.T) {
+	db := setupDB(t)
+	r := New(db, logrus.New())
+	ctx := WithActor(context.Background(), Actor{Subject: "test-auditor", Role: "admin", RequestID: "test-audit-request"})
+
+	user := "test-audit-user"
+	if err := r.EnsureUserExists(ctx, user, "Test Audit User"); err != nil {
+		t.Fatalf("ensure user failed: %v", err)
+	}
+	key := "test-audit-" + time.Now().Format(time.RFC3339Nano)
+	id, _, err := r.CreateReward(ctx, RewardInput{UserID: user, Symbol: "TCS", Quantity: decimal.NewFromInt(1), Timestamp: time.Now().UTC(),
+		IdempotencyKey: key, Source: "test", Price: decimal.NewFromInt(100)})
+	if err != nil {
+		t.Fatalf("create reward failed: %v", err)
+	}
+	if err := r.ReverseReward(ctx, id, Reversal{Reason: ReversalDuplicate, Note: "granted twice", ReversedBy: "test-auditor"}); err != nil {
+		t.Fatalf("reverse failed: %v", err)
+	}
+
+	rows, _, err := r.ListAuditEvents(ctx, AuditFilter{EntityType: "reward", EntityID: id, Limit: 10})
+	if err != nil {
+		t.Fatalf("list audit events failed: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Action != AuditRewardReverse || rows[1].Action != AuditRewardCreate {
+		t.Fatalf("expected reverse then create events, got %+v", rows)
+	}
+	rev := rows[0]
+	if rev.Actor != "test-auditor" || rev.RequestID != "test-audit-request" || rev.Reason != ReversalDuplicate {
+		t.Fatalf("reverse event should record actor, request and reason, got %+v", rev)
+	}
+	var before, after struct{ Status string }
+	if json.Unmarshal(rev.Before, &before) != nil || json.Unmarshal(rev.After, &after) != nil || before.Status != StatusCompleted || after.Status != StatusReversed {
+		t.Fatalf("expected COMPLETED -> REVERSED snapshots, got %s -> %s", rev.Before, rev.After)
+	}
+
+	if _, err := db.Exec("UPDATE audit_events SET reason = 'edited' WHERE id = $1", rev.ID); err == nil {
+		t.Fatal("audit events should not be updatable")
+	}
+	v, err := r.VerifyAuditChain(ctx)
+	if err != nil || !v.Valid {
+		t.Fatalf("audit chain should verify, got %+v (err %v)", v, err)
+	}
+}
@@ -0,0 +1,220 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"stocky/internal/vesting"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrCampaignUnavailable = errors.New("campaign is not active")
+	ErrCampaignBudget      = errors.New("campaign budget exhausted")
+	ErrCampaignUserCap     = errors.New("campaign per-user cap reached")
+	ErrCampaignExists      = errors.New("a campaign with this name already exists")
+)
+
+// Campaign funds rewards from a fixed INR budget during its active window.
+// Empty EligibleSymbols allows any symbol; zero per-user caps are not
+// enforced.
+type Campaign struct {
+	ID                string          `db:"id" json:"id"`
+	Name              string          `db:"name" json:"name"`
+	StartsAt          time.Time       `db:"starts_at" json:"starts_at"`
+	EndsAt            *time.Time      `db:"ends_at" json:"ends_at,omitempty"`
+	BudgetINR         decimal.Decimal `db:"budget_inr" json:"budget_inr"`
+	SpentINR          decimal.Decimal `db:"spent_inr" json:"spent_inr"`
+	EligibleSymbols   pq.StringArray  `db:"eligible_symbols" json:"eligible_symbols"`
+	MaxRewardsPerUser int             `db:"max_rewards_per_user" json:"max_rewards_per_user"`
+	MaxINRPerUser     decimal.Decimal `db:"max_inr_per_user" json:"max_inr_per_user"`
+	Active            bool            `db:"active" json:"active"`
+	CreatedAt         time.Time       `db:"created_at" json:"created_at"`
+}
+
+const campaignColumns = `id, name, starts_at, ends_at, budget_inr, spent_inr, eligible_symbols, max_rewards_per_user, max_inr_per_user, active, created_at`
+
+func (c Campaign) ActiveAt(t time.Time) bool {
+	return c.Active && !t.Before(c.StartsAt) && (c.EndsAt == nil || t.Before(*c.EndsAt))
+}
+
+func (c Campaign) AllowsSymbol(symbol string) bool {
+	if len(c.EligibleSymbols) == 0 {
+		return true
+	}
+	for _, s := range c.EligibleSymbols {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// CampaignRule grants Quantity of Symbol for events of EventType whose value
+// is at least MinValue.
+type CampaignRule struct {
+	ID          string          `db:"id" json:"id"`
+	CampaignID  string          `db:"campaign_id" json:"campaign_id"`
+	EventType   string          `db:"event_type" json:"event_type"`
+	MinValue    decimal.Decimal `db:"min_value" json:"min_value"`
+	Symbol      string          `db:"symbol" json:"symbol"`
+	Quantity    decimal.Decimal `db:"quantity" json:"quantity"`
+	CliffMonths *int            `db:"vesting_cliff_months" json:"vesting_cliff_months,omitempty"`
+	Months      *int            `db:"vesting_months" json:"vesting_months,omitempty"`
+	Priority    int             `db:"priority" json:"priority"`
+	CreatedAt   time.Time       `db:"created_at" json:"created_at"`
+}
+
+const campaignRuleColumns = `id, campaign_id, event_type, min_value, symbol, quantity, vesting_cliff_months, vesting_months, priority, created_at`
+
+func (r CampaignRule) Vesting() *vesting.Schedule {
+	if r.CliffMonths == nil || r.Months == nil {
+		return nil
+	}
+	return &vesting.Schedule{CliffMonths: *r.CliffMonths, Months: *r.Months}
+}
+
+// MatchRules returns the rules of campaigns active at t that fire for an
+// event, highest priority first and older rules first within a priority.
+func MatchRules(campaigns []Campaign, rules []CampaignRule, eventType string, value decimal.Decimal, t time.Time) []CampaignRule {
+	active := map[string]Campaign{}
+	for _, c := range campaigns {
+		if c.ActiveAt(t) {
+			active[c.ID] = c
+		}
+	}
+	res := []CampaignRule{}
+	for _, rl := range rules {
+		c, ok := active[rl.CampaignID]
+		if !ok || rl.EventType != eventType || value.LessThan(rl.MinValue) || !c.AllowsSymbol(rl.Symbol) {
+			continue
+		}
+		res = append(res, rl)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Priority != res[j].Priority {
+			return res[i].Priority > res[j].Priority
+		}
+		return res[i].CreatedAt.Before(res[j].CreatedAt)
+	})
+	return res
+}
+
+// CreateCampaign returns ErrCampaignExists if the name is taken.
+func (r *Repo) CreateCampaign(ctx context.Context, c Campaign) (Campaign, error) {
+	var res Campaign
+	err := r.db.GetContext(ctx, &res, `INSERT INTO campaigns (name, starts_at, ends_at, budget_inr, eligible_symbols, max_rewards_per_user, max_inr_per_user, active) VALUES ($1, $2, $3, $4::numeric, $5, $6, $7::numeric, $8) RETURNING `+campaignColumns,
+		c.Name, c.StartsAt, c.EndsAt, c.BudgetINR.StringFixed(4), c.EligibleSymbols, c.MaxRewardsPerUser, c.MaxINRPerUser.StringFixed(4), c.Active)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" && pqErr.Constraint == "campaigns_name_key" {
+		return res, ErrCampaignExists
+	}
+	return res, err
+}
+
+// GetCampaign returns sql.ErrNoRows for unknown ids, malformed ones included.
+func (r *Repo) GetCampaign(ctx context.Context, id string) (Campaign, error) {
+	var res Campaign
+	if !ValidUUID(id) {
+		return res, sql.ErrNoRows
+	}
+	err := r.db.GetContext(ctx, &res, `SELECT `+campaignColumns+` FROM campaigns WHERE id = $1`, id)
+	return res, err
+}
+
+func (r *Repo) ListCampaigns(ctx context.Context) ([]Campaign, error) {
+	res := []Campaign{}
+	err := r.db.SelectContext(ctx, &res, `SELECT `+campaignColumns+` FROM campaigns ORDER BY created_at DESC`)
+	return res, err
+}
+
+// SetCampaignActive pauses or resumes a campaign. It returns sql.ErrNoRows
+// for unknown or malformed ids.
+func (r *Repo) SetCampaignActive(ctx context.Context, id string, active bool) (Campaign, error) {
+	var res Campaign
+	if !ValidUUID(id) {
+		return res, sql.ErrNoRows
+	}
+	err := r.db.GetContext(ctx, &res, `UPDATE campaigns SET active = $2 WHERE id = $1 RETURNING `+campaignColumns, id, active)
+	return res, err
+}
+
+func (r *Repo) CreateCampaignRule(ctx context.Context, rl CampaignRule) (CampaignRule, error) {
+	var res CampaignRule
+	err := r.db.GetContext(ctx, &res, `INSERT INTO campaign_rules (campaign_id, event_type, min_value, symbol, quantity, vesting_cliff_months, vesting_months, priority) VALUES ($1, $2, $3::numeric, $4, $5::numeric, $6, $7, $8) RETURNING `+campaignRuleColumns,
+		rl.CampaignID, rl.EventType, rl.MinValue.StringFixed(4), rl.Symbol, rl.Quantity.StringFixed(6), rl.CliffMonths, rl.Months, rl.Priority)
+	return res, err
+}
+
+func (r *Repo) ListCampaignRules(ctx context.Context, campaignID string) ([]CampaignRule, error) {
+	res := []CampaignRule{}
+	err := r.db.SelectContext(ctx, &res, `SELECT `+campaignRuleColumns+` FROM campaign_rules WHERE ($1 = '' OR campaign_id::text = $1) ORDER BY priority DESC, created_at ASC`, campaignID)
+	return res, err
+}
+
+// ActiveCampaignRules loads the campaigns active at t and their rules for
+// eventType, for MatchRules.
+func (r *Repo) ActiveCampaignRules(ctx context.Context, eventType string, t time.Time) ([]Campaign, []CampaignRule, error) {
+	campaigns := []Campaign{}
+	if err := r.db.SelectContext(ctx, &campaigns, `SELECT `+campaignColumns+` FROM campaigns WHERE active AND starts_at <= $1 AND (ends_at IS NULL OR ends_at > $1) AND spent_inr < budget_inr`, t); err != nil {
+		return nil, nil, err
+	}
+	rules := []CampaignRule{}
+	if err := r.db.SelectContext(ctx, &rules, `
+		SELECT `+campaignRuleColumns+` FROM campaign_rules
+		WHERE event_type = $1 AND campaign_id IN (SELECT id FROM campaigns WHERE active AND starts_at <= $2 AND (ends_at IS NULL OR ends_at > $2))`, eventType, t); err != nil {
+		return nil, nil, err
+	}
+	return campaigns, rules, nil
+}
+
+// chargeCampaign reserves value INR of a campaign's budget for a reward
+// being booked in tx. The campaign row lock serialises concurrent charges,
+// so the budget and per-user caps cannot be overrun. value is rounded to the
+// 4 places spent_inr keeps, as refundCampaign does, before it is checked.
+func chargeCampaign(ctx context.Context, tx *sqlx.Tx, campaignID, userID, symbol string, value decimal.Decimal, now time.Time) error {
+	value = value.Round(4)
+	var c Campaign
+	if err := tx.GetContext(ctx, &c, `SELECT `+campaignColumns+` FROM campaigns WHERE id = $1 FOR UPDATE`, campaignID); err != nil {
+		return err
+	}
+	if !c.ActiveAt(now) || !c.AllowsSymbol(symbol) {
+		return ErrCampaignUnavailable
+	}
+	if c.SpentINR.Add(value).GreaterThan(c.BudgetINR) {
+		return ErrCampaignBudget
+	}
+	if c.MaxRewardsPerUser > 0 || c.MaxINRPerUser.IsPositive() {
+		var count int
+		var spent decimal.Decimal
+		if err := tx.QueryRowContext(ctx, `
+			SELECT count(*), COALESCE(SUM(ROUND(quantity * price_inr, 4)), 0)
+			FROM rewards
+			WHERE campaign_id = $1 AND user_id = $2 AND status IN ('COMPLETED', 'PENDING_APPROVAL')`, campaignID, userID).Scan(&count, &spent); err != nil {
+			return err
+		}
+		if c.MaxRewardsPerUser > 0 && count >= c.MaxRewardsPerUser {
+			return ErrCampaignUserCap
+		}
+		if c.MaxINRPerUser.IsPositive() && spent.Add(value).GreaterThan(c.MaxINRPerUser) {
+			return ErrCampaignUserCap
+		}
+	}
+	_, err := tx.ExecContext(ctx, `UPDATE campaigns SET spent_inr = spent_inr + $2::numeric WHERE id = $1`, campaignID, value.StringFixed(4))
+	return err
+}
+
+// refundCampaign returns a reversed or rejected reward's value to its
+// campaign's budget.
+func refundCampaign(ctx context.Context, tx *sqlx.Tx, rewardID string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE campaigns c SET spent_inr = GREATEST(c.spent_inr - ROUND(rw.quantity * rw.price_inr, 4), 0)
+		FROM rewards rw
+		WHERE rw.id = $1 AND c.id = rw.campaign_id`, rewardID)
+	return err
+}
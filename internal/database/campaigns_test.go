@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMatchRules(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	ended := now.Add(-time.Hour)
+	campaigns := []Campaign{
+		{ID: "live", Active: true, StartsAt: now.AddDate(0, -1, 0), EligibleSymbols: []string{"TCS", "INFY"}},
+		{ID: "paused", Active: false, StartsAt: now.AddDate(0, -1, 0)},
+		{ID: "over", Active: true, StartsAt: now.AddDate(0, -1, 0), EndsAt: &ended},
+	}
+	rules := []CampaignRule{
+		{ID: "low", CampaignID: "live", EventType: "trade_milestone", MinValue: decimal.NewFromInt(10), Symbol: "INFY", CreatedAt: now.Add(-2 * time.Hour)},
+		{ID: "high", CampaignID: "live", EventType: "trade_milestone", MinValue: decimal.NewFromInt(100), Symbol: "TCS", Priority: 5},
+		{ID: "older", CampaignID: "live", EventType: "trade_milestone", Symbol: "TCS", CreatedAt: now.Add(-3 * time.Hour)},
+		{ID: "ineligible", CampaignID: "live", EventType: "trade_milestone", Symbol: "RELIANCE"},
+		{ID: "other-event", CampaignID: "live", EventType: "referral", Symbol: "TCS"},
+		{ID: "paused", CampaignID: "paused", EventType: "trade_milestone", Symbol: "TCS"},
+		{ID: "over", CampaignID: "over", EventType: "trade_milestone", Symbol: "TCS"},
+	}
+
+	got := MatchRules(campaigns, rules, "trade_milestone", decimal.NewFromInt(150), now)
+	want := []string{"high", "older", "low"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d rules, want %v", len(got), want)
+	}
+	for i, id := range want {
+		if got[i].ID != id {
+			t.Errorf("rule %d = %s, want %s", i, got[i].ID, id)
+		}
+	}
+
+	if got := MatchRules(campaigns, rules, "trade_milestone", decimal.NewFromInt(5), now); len(got) != 1 || got[0].ID != "older" {
+		t.Errorf("value below thresholds should only match the rule without one, got %+v", got)
+	}
+}
+
+func TestCampaignBudgetCapsAndRefunds(t *testing.T) {
+	db := setupDB(t)
+	r := New(db, logrus.New())
+	ctx := context.Background()
+
+	users := []string{"test-campaign-user-1", "test-campaign-user-2"}
+	for _, u := range users {
+		if _, err := db.Exec("INSERT INTO users (id, name) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING", u, u); err != nil {
+			t.Fatalf("create user failed: %v", err)
+		}
+		_, _ = db.Exec("DELETE FROM approvals WHERE reward_id IN (SELECT id FROM rewards WHERE user_id = $1)", u)
+		_, _ = db.Exec("DELETE FROM ledger_entries WHERE reward_id IN (SELECT id FROM rewards WHERE user_id = $1)", u)
+		_, _ = db.Exec("DELETE FROM rewards WHERE user_id = $1", u)
+		_, _ = db.Exec("DELETE FROM holdings WHERE user_id = $1", u)
+	}
+
+	c, err := r.CreateCampaign(ctx, Campaign{
+		Name:          fmt.Sprintf("test-campaign-%d", time.Now().UnixNano()),
+		StartsAt:      time.Now().Add(-time.Hour),
+		BudgetINR:     decimal.NewFromInt(100),
+		MaxINRPerUser: decimal.NewFromInt(60),
+		Active:        true,
+	})
+	if err != nil {
+		t.Fatalf("create campaign failed: %v", err)
+	}
+	if _, err := r.CreateCampaign(ctx, c); !errors.Is(err, ErrCampaignExists) {
+		t.Fatalf("expected ErrCampaignExists for a duplicate name, got %v", err)
+	}
+	if _, err := r.GetCampaign(ctx, "not-a-uuid"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for a malformed id, got %v", err)
+	}
+	if _, err := r.SetCampaignActive(ctx, "not-a-uuid", false); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for a malformed id, got %v", err)
+	}
+	n := 0
+	grant := func(userID, quantity string, approval bool) (string, error) {
+		n++
+		id, _, err := r.CreateReward(ctx, RewardInput{
+			UserID: userID, Symbol: "TCS", Quantity: decimal.RequireFromString(quantity), Timestamp: time.Now().UTC(),
+			IdempotencyKey: fmt.Sprintf("%s-%d", c.Name, n), Source: "test", Price: decimal.NewFromInt(150),
+			CampaignID: c.ID, RequiresApproval: approval, RequestedBy: "maker",
+		})
+		return id, err
+	}
+	expectSpent := func(want string) {
+		t.Helper()
+		got, err := r.GetCampaign(ctx, c.ID)
+		if err != nil {
+			t.Fatalf("get campaign failed: %v", err)
+		}
+		if !got.SpentINR.Equal(decimal.RequireFromString(want)) {
+			t.Fatalf("expected spent_inr %s, got %s", want, got.SpentINR)
+		}
+	}
+
+	// 0.333333 * 150 = 49.99995, charged as 50.0000 like the refund rounds it.
+	first, err := grant(users[0], "0.333333", false)
+	if err != nil {
+		t.Fatalf("grant failed: %v", err)
+	}
+	expectSpent("50")
+	if _, err := grant(users[0], "0.1", false); !errors.Is(err, ErrCampaignUserCap) {
+		t.Fatalf("expected ErrCampaignUserCap, got %v", err)
+	}
+	if _, err := grant(users[1], "0.3", false); err != nil {
+		t.Fatalf("grant failed: %v", err)
+	}
+	expectSpent("95")
+	if _, err := grant(users[1], "0.1", false); !errors.Is(err, ErrCampaignBudget) {
+		t.Fatalf("expected ErrCampaignBudget, got %v", err)
+	}
+	expectSpent("95")
+
+	if err := r.ReverseReward(ctx, first, Reversal{Reason: ReversalOpsError, Note: "test", ReversedBy: "test-admin"}); err != nil {
+		t.Fatalf("reverse reward failed: %v", err)
+	}
+	expectSpent("45")
+
+	pending, err := grant(users[0], "0.2", true)
+	if err != nil {
+		t.Fatalf("grant failed: %v", err)
+	}
+	expectSpent("75")
+	if err := r.RejectReward(ctx, pending, "checker", "not eligible"); err != nil {
+		t.Fatalf("reject failed: %v", err)
+	}
+	expectSpent("45")
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"stocky/internal/policy"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+)
+
+// rewardUsage returns the INR value already granted to userID and by source,
+// the daily totals counting from dayStart. It takes transaction-scoped
+// advisory locks on both first, so concurrent rewards for the same user or
+// source are evaluated one after another.
+func rewardUsage(ctx context.Context, tx *sqlx.Tx, userID, source string, dayStart time.Time) (policy.Usage, error) {
+	var u policy.Usage
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('reward-user:' || $1))`, userID); err != nil {
+		return u, err
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('reward-source:' || $1))`, source); err != nil {
+		return u, err
+	}
+
+	var userDay, userLifetime, sourceDay string
+	if err := tx.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(SUM(quantity * price_inr) FILTER (WHERE created_at >= $2), 0)::text,
+			COALESCE(SUM(quantity * price_inr), 0)::text
+		FROM rewards
+		WHERE user_id = $1 AND status IN ('COMPLETED', 'PENDING_APPROVAL')`, userID, dayStart).Scan(&userDay, &userLifetime); err != nil {
+		return u, err
+	}
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(quantity * price_inr), 0)::text
+		FROM rewards
+		WHERE source = $1 AND status IN ('COMPLETED', 'PENDING_APPROVAL') AND created_at >= $2`, source, dayStart).Scan(&sourceDay); err != nil {
+		return u, err
+	}
+	u.UserDayINR, _ = decimal.NewFromString(userDay)
+	u.UserLifetimeINR, _ = decimal.NewFromString(userLifetime)
+	u.SourceDayINR, _ = decimal.NewFromString(sourceDay)
+	return u, nil
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"stocky/internal/tax"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+)
+
+// ErrLotShortfall means a user's open lots hold fewer shares than an outflow
+// takes from their holdings, so the two have drifted apart.
+var ErrLotShortfall = errors.New("open lots do not cover the quantity")
+
+// Lot sources.
+const (
+	LotReward   = "reward"
+	LotTransfer = "transfer"
+	LotBackfill = "backfill"
+)
+
+// Lot is one acquisition of shares still (partly) held, at its cost.
+type Lot struct {
+	ID                string          `db:"id" json:"id"`
+	UserID            string          `db:"user_id" json:"-"`
+	Symbol            string          `db:"symbol" json:"-"`
+	AcquiredAt        time.Time       `db:"acquired_at" json:"acquired_at"`
+	Quantity          decimal.Decimal `db:"quantity" json:"quantity"`
+	RemainingQuantity decimal.Decimal `db:"remaining_quantity" json:"remaining_quantity"`
+	CostPerShareINR   decimal.Decimal `db:"cost_per_share_inr" json:"cost_per_share_inr"`
+	SourceType        string          `db:"source_type" json:"source_type"`
+	SourceID          *string         `db:"source_id" json:"source_id,omitempty"`
+}
+
+const lotColumns = `id, user_id, symbol, acquired_at, quantity, remaining_quantity, cost_per_share_inr, source_type, source_id`
+
+// LotUse is the part of a lot taken by an outflow.
+type LotUse struct {
+	Lot      Lot
+	Quantity decimal.Decimal
+}
+
+// allocateFIFO takes quantity from lots in the order given. If the lots hold
+// less than quantity, everything they hold is taken.
+func allocateFIFO(lots []Lot, quantity decimal.Decimal) []LotUse {
+	uses := []LotUse{}
+	left := quantity
+	for _, l := range lots {
+		if !left.IsPositive() {
+			break
+		}
+		take := decimal.Min(l.RemainingQuantity, left)
+		if !take.IsPositive() {
+			continue
+		}
+		uses = append(uses, LotUse{Lot: l, Quantity: take})
+		left = left.Sub(take)
+	}
+	return uses
+}
+
+func addLot(ctx context.Context, tx *sqlx.Tx, userID, symbol string, quantity, cost decimal.Decimal, acquiredAt time.Time, sourceType string, sourceID *string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO holding_lots (user_id, symbol, acquired_at, quantity, remaining_quantity, cost_per_share_inr, source_type, source_id) VALUES ($1, $2, $3, $4::numeric, $4::numeric, $5::numeric, $6, $7)`,
+		userID, symbol, acquiredAt, quantity.StringFixed(6), cost.StringFixed(4), sourceType, sourceID)
+	return err
+}
+
+// consumeLots takes quantity of a user's symbol from their open lots, oldest
+// first. Lots acquired from sourceID, if given, are taken before any other.
+// It returns ErrLotShortfall if the open lots hold less than quantity.
+func consumeLots(ctx context.Context, tx *sqlx.Tx, userID, symbol string, quantity decimal.Decimal, sourceID *string) ([]LotUse, error) {
+	lots := []Lot{}
+	if err := tx.SelectContext(ctx, &lots, `SELECT `+lotColumns+` FROM holding_lots WHERE user_id = $1 AND symbol = $2 AND remaining_quantity > 0
+		ORDER BY (source_id = $3::uuid) IS TRUE DESC, acquired_at, created_at, id FOR UPDATE`, userID, symbol, sourceID); err != nil {
+		return nil, err
+	}
+	uses := allocateFIFO(lots, quantity)
+	taken := decimal.Zero
+	for _, u := range uses {
+		taken = taken.Add(u.Quantity)
+	}
+	if taken.LessThan(quantity) {
+		return nil, fmt.Errorf("%w: %s %s wants %s, lots hold %s", ErrLotShortfall, userID, symbol, quantity, taken)
+	}
+	for _, u := range uses {
+		if _, err := tx.ExecContext(ctx, `UPDATE holding_lots SET remaining_quantity = remaining_quantity - $2::numeric WHERE id = $1`, u.Lot.ID, u.Quantity.String()); err != nil {
+			return nil, err
+		}
+	}
+	return uses, nil
+}
+
+// openLots returns a user's open lots grouped by symbol, oldest first.
+func (r *Repo) openLots(ctx context.Context, userID string) (map[string][]Lot, error) {
+	lots := []Lot{}
+	if err := r.db.SelectContext(ctx, &lots, `SELECT `+lotColumns+` FROM holding_lots WHERE user_id = $1 AND remaining_quantity > 0 ORDER BY symbol, acquired_at, created_at, id`, userID); err != nil {
+		return nil, err
+	}
+	res := map[string][]Lot{}
+	for _, l := range lots {
+		res[l.Symbol] = append(res[l.Symbol], l)
+	}
+	return res, nil
+}
+
+// recordDisposals stores the lots a sale consumed. The sale's net proceeds
+// are split across them by quantity; the last one takes the rounding
+// remainder so the parts add up to the whole.
+func recordDisposals(ctx context.Context, tx *sqlx.Tx, s Sale, uses []LotUse) error {
+	left := s.NetINR
+	for i, u := range uses {
+		proceeds := s.NetINR.Mul(u.Quantity).DivRound(s.Quantity, 4)
+		if i == len(uses)-1 {
+			proceeds = left
+		}
+		left = left.Sub(proceeds)
+		if _, err := tx.ExecContext(ctx, `INSERT INTO lot_disposals (sale_id, lot_id, user_id, symbol, quantity, acquired_at, sold_at, cost_inr, proceeds_inr) VALUES ($1, $2, $3, $4, $5::numeric, $6, $7, $8::numeric, $9::numeric)`,
+			s.ID, u.Lot.ID, s.UserID, s.Symbol, u.Quantity.StringFixed(6), u.Lot.AcquiredAt, s.CreatedAt, u.Quantity.Mul(u.Lot.CostPerShareINR).StringFixed(4), proceeds.StringFixed(4)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListDisposals returns the lots a user sold in [from, to), oldest first.
+func (r *Repo) ListDisposals(ctx context.Context, userID string, from, to time.Time) ([]tax.Disposal, error) {
+	rows := []struct {
+		Symbol      string          `db:"symbol"`
+		AcquiredAt  time.Time       `db:"acquired_at"`
+		SoldAt      time.Time       `db:"sold_at"`
+		Quantity    decimal.Decimal `db:"quantity"`
+		CostINR     decimal.Decimal `db:"cost_inr"`
+		ProceedsINR decimal.Decimal `db:"proceeds_inr"`
+	}{}
+	if err := r.db.SelectContext(ctx, &rows, `SELECT symbol, acquired_at, sold_at, quantity, cost_inr, proceeds_inr FROM lot_disposals WHERE user_id = $1 AND sold_at >= $2 AND sold_at < $3 ORDER BY sold_at, id`, userID, from, to); err != nil {
+		return nil, err
+	}
+	res := make([]tax.Disposal, 0, len(rows))
+	for _, d := range rows {
+		res = append(res, tax.Disposal(d))
+	}
+	return res, nil
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+func TestAllocateFIFO(t *testing.T) {
+	lots := []Lot{
+		{ID: "a", RemainingQuantity: decimal.RequireFromString("1.5")},
+		{ID: "b", RemainingQuantity: decimal.Zero},
+		{ID: "c", RemainingQuantity: decimal.NewFromInt(2)},
+		{ID: "d", RemainingQuantity: decimal.NewFromInt(4)},
+	}
+	uses := allocateFIFO(lots, decimal.RequireFromString("2.25"))
+	if len(uses) != 2 || uses[0].Lot.ID != "a" || uses[1].Lot.ID != "c" {
+		t.Fatalf("expected lots a and c, got %+v", uses)
+	}
+	if !uses[0].Quantity.Equal(decimal.RequireFromString("1.5")) || !uses[1].Quantity.Equal(decimal.RequireFromString("0.75")) {
+		t.Fatalf("unexpected quantities %s, %s", uses[0].Quantity, uses[1].Quantity)
+	}
+
+	uses = allocateFIFO(lots, decimal.NewFromInt(10))
+	total := decimal.Zero
+	for _, u := range uses {
+		total = total.Add(u.Quantity)
+	}
+	if len(uses) != 3 || !total.Equal(decimal.RequireFromString("7.5")) {
+		t.Fatalf("short lots should all be taken, got %+v", uses)
+	}
+}
+
+func TestGainPct(t *testing.T) {
+	if got := GainPct(decimal.NewFromInt(25), decimal.NewFromInt(200)); got.StringFixed(2) != "12.50" {
+		t.Errorf("GainPct(25, 200) = %s", got)
+	}
+	if got := GainPct(decimal.RequireFromString("-1"), decimal.NewFromInt(3)); got.StringFixed(2) != "-33.33" {
+		t.Errorf("GainPct(-1, 3) = %s", got)
+	}
+	if got := GainPct(decimal.NewFromInt(5), decimal.Zero); !got.IsZero() {
+		t.Errorf("GainPct with nothing invested = %s", got)
+	}
+}
+
+func TestPortfolioCostBasis(t *testing.T) {
+	db := setupDB(t)
+	r := New(db, logrus.New())
+	ctx := context.Background()
+
+	user, symbol := "test-lots-user", "RELIANCE"
+	if _, err := db.Exec("INSERT INTO users (id, name) VALUES ($1, $1) ON CONFLICT (id) DO NOTHING", user); err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	_, _ = db.Exec("DELETE FROM holding_lots WHERE user_id = $1", user)
+	_, _ = db.Exec("DELETE FROM holdings WHERE user_id = $1", user)
+	if _, err := db.Exec("INSERT INTO holdings (user_id, symbol, quantity) VALUES ($1, $2, 3)", user, symbol); err != nil {
+		t.Fatalf("seed holdings failed: %v", err)
+	}
+	for i, cost := range []int64{100, 200} {
+		if _, err := db.Exec("INSERT INTO holding_lots (user_id, symbol, acquired_at, quantity, remaining_quantity, cost_per_share_inr, source_type) VALUES ($1, $2, now() - $3 * interval '1 day', 2, 2, $4, 'backfill')",
+			user, symbol, 2-i, cost); err != nil {
+			t.Fatalf("seed lot failed: %v", err)
+		}
+	}
+
+	// Selling one share takes it from the older, cheaper lot.
+	tx, err := db.Beginx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := consumeLots(ctx, tx, user, symbol, decimal.NewFromInt(1), nil); err != nil {
+		t.Fatalf("consume lots failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The lots now hold 3 shares; taking more must fail rather than
+	// silently take what is there.
+	tx, err = db.Beginx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := consumeLots(ctx, tx, user, symbol, decimal.NewFromInt(4), nil); !errors.Is(err, ErrLotShortfall) {
+		t.Fatalf("expected ErrLotShortfall, got %v", err)
+	}
+	_ = tx.Rollback()
+
+	items, _, err := r.GetPortfolio(ctx, user)
+	if err != nil {
+		t.Fatalf("get portfolio failed: %v", err)
+	}
+	if len(items) != 1 {
+		t.Skipf("no price for %s: %v", symbol, items)
+	}
+	it := items[0]
+	if !it.InvestedINR.Equal(decimal.NewFromInt(500)) || len(it.Lots) != 2 {
+		t.Fatalf("expected 500 invested over 2 lots, got %s %+v", it.InvestedINR, it.Lots)
+	}
+	if it.AverageCostINR.StringFixed(4) != "166.6667" {
+		t.Fatalf("expected average cost 166.6667, got %s", it.AverageCostINR)
+	}
+	if !it.UnrealisedINR.Equal(it.CurrentValue.Sub(it.InvestedINR)) {
+		t.Fatalf("unrealised %s does not match value %s - invested %s", it.UnrealisedINR, it.CurrentValue, it.InvestedINR)
+	}
+}
+
+// seedLot opens a lot of quantity shares at no cost, for tests that seed
+// holdings directly.
+func seedLot(db *sqlx.DB, userID, symbol string, quantity int64) error {
+	_, err := db.Exec("INSERT INTO holding_lots (user_id, symbol, acquired_at, quantity, remaining_quantity, cost_per_share_inr, source_type) VALUES ($1, $2, now(), $3, $3, 0, 'backfill')",
+		userID, symbol, quantity)
+	return err
+}
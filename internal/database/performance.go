@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"stocky/internal/performance"
+
+	"github.com/shopspring/decimal"
+)
+
+// CashFlows returns the value that moved into or out of a user's holdings,
+// matching what ComputeHistoricalValuations counts: completed rewards at
+// their booking price (vesting grants tranche by tranche as they vest),
+// transfers at their transfer price, sales at their gross value and settled
+// withdrawals at the value posted to the ledger.
+func (r *Repo) CashFlows(ctx context.Context, userID string) ([]performance.Flow, error) {
+	rows := []struct {
+		Time   time.Time       `db:"t"`
+		Amount decimal.Decimal `db:"amount"`
+	}{}
+	if err := r.db.SelectContext(ctx, &rows, `
+		SELECT timestamp AS t, quantity * price_inr AS amount FROM rewards
+			WHERE user_id = $1 AND status = 'COMPLETED' AND vesting_months IS NULL AND price_inr IS NOT NULL
+		UNION ALL SELECT created_at, quantity * price_inr FROM transfers WHERE to_user_id = $1
+		UNION ALL SELECT created_at, -quantity * price_inr FROM transfers WHERE from_user_id = $1
+		UNION ALL SELECT created_at, -gross_inr FROM sales WHERE user_id = $1
+		UNION ALL SELECT entry_time, -amount_inr FROM ledger_entries WHERE ref_type = 'withdrawal' AND account_debit = 'user_holdings:' || $1
+		ORDER BY 1`, userID); err != nil {
+		return nil, err
+	}
+	res := make([]performance.Flow, 0, len(rows))
+	for _, row := range rows {
+		res = append(res, performance.Flow{Time: row.Time, Amount: row.Amount})
+	}
+
+	grants, err := r.vestingGrants(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, g := range grants {
+		held := decimal.Zero
+		for m := 1; m <= g.Months; m++ {
+			at := g.Timestamp.AddDate(0, m, 0)
+			if at.After(now) {
+				break
+			}
+			next := g.heldAt(at)
+			if delta := next.Sub(held); delta.IsPositive() {
+				res = append(res, performance.Flow{Time: at, Amount: delta.Mul(g.Price)})
+			}
+			held = next
+		}
+	}
+	return res, nil
+}
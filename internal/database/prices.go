@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type QuarantinedPrice struct {
+	ID           int64            `db:"id" json:"id"`
+	Symbol       string           `db:"symbol" json:"symbol"`
+	PriceINR     decimal.Decimal  `db:"price_inr" json:"price_inr"`
+	LastPriceINR *decimal.Decimal `db:"last_price_inr" json:"last_price_inr,omitempty"`
+	MovePct      *decimal.Decimal `db:"move_pct" json:"move_pct,omitempty"`
+	Reason       string           `db:"reason" json:"reason"`
+	Timestamp    time.Time        `db:"timestamp" json:"timestamp"`
+	CreatedAt    time.Time        `db:"created_at" json:"created_at"`
+}
+
+func (r *Repo) QuarantinePrice(ctx context.Context, q QuarantinedPrice) error {
+	var last, move interface{}
+	if q.LastPriceINR != nil {
+		last = q.LastPriceINR.StringFixed(4)
+	}
+	if q.MovePct != nil {
+		move = q.MovePct.StringFixed(4)
+	}
+	_, err := r.db.ExecContext(ctx, `INSERT INTO price_quarantine (symbol, price_inr, last_price_inr, move_pct, reason, timestamp) VALUES ($1, $2::numeric, $3::numeric, $4::numeric, $5, $6)`,
+		q.Symbol, q.PriceINR.StringFixed(4), last, move, q.Reason, q.Timestamp)
+	return err
+}
+
+func (r *Repo) ListQuarantinedPrices(ctx context.Context, symbol string, limit int) ([]QuarantinedPrice, error) {
+	res := []QuarantinedPrice{}
+	err := r.db.SelectContext(ctx, &res, `
+		SELECT id, symbol, price_inr, last_price_inr, move_pct, reason, timestamp, created_at
+		FROM price_quarantine
+		WHERE ($1 = '' OR symbol = $1)
+		ORDER BY timestamp DESC
+		LIMIT $2`, symbol, limit)
+	return res, err
+}
+
+// OverridePrice records an operator-set price for symbol at ts, which the
+// price providers serve until the next tick. It returns sql.ErrNoRows for
+// unknown symbols.
+func (r *Repo) OverridePrice(ctx context.Context, symbol string, price decimal.Decimal, ts time.Time, reason string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM stocks WHERE symbol = $1)`, symbol); err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	before, err := snapshot(ctx, tx, `SELECT row_to_json(t) FROM price_history t WHERE symbol = $1 ORDER BY timestamp DESC LIMIT 1`, symbol)
+	if err != nil {
+		return err
+	}
+	var after []byte
+	if err := tx.QueryRowContext(ctx, `INSERT INTO price_history (symbol, price_inr, timestamp) VALUES ($1, $2::numeric, $3) RETURNING row_to_json(price_history)`,
+		symbol, price.StringFixed(4), ts).Scan(&after); err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, tx, AuditPriceOverride, "price", symbol, before, after, reason); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -3,8 +3,14 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
 
+	"stocky/internal/calendar"
+	"stocky/internal/events"
+	"stocky/internal/policy"
+	"stocky/internal/vesting"
+
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
@@ -12,15 +18,65 @@ import (
 )
 
 type Repo struct {
-	db  *sqlx.DB
-	log *logrus.Logger
+	db       *sqlx.DB
+	log      *logrus.Logger
+	calendar *calendar.Calendar
+	location *time.Location
 }
 
-func New(db *sqlx.DB, log *logrus.Logger) *Repo {
-	return &Repo{db: db, log: log}
+type Option func(*Repo)
+
+// WithCalendar makes valuations price each day at the last trading close
+// instead of the last quote before midnight.
+func WithCalendar(c *calendar.Calendar) Option {
+	return func(r *Repo) { r.calendar = c }
+}
+
+func New(db *sqlx.DB, log *logrus.Logger, opts ...Option) *Repo {
+	r := &Repo{db: db, log: log, location: calendar.IST}
+	for _, o := range opts {
+		o(r)
+	}
+	return r
+}
+
+const (
+	StatusCompleted       = "COMPLETED"
+	StatusReversed        = "REVERSED"
+	StatusPendingApproval = "PENDING_APPROVAL"
+	StatusRejected        = "REJECTED"
+)
+
+// RewardInput describes a reward to book. Limits, when set, are evaluated
+// inside the booking transaction against already granted rewards. Rewards
+// that require approval are recorded as PENDING_APPROVAL and only credited
+// once a second admin approves them. Rewards with a Vesting schedule move
+// into holdings tranche by tranche instead of at once. A CampaignID charges
+// the reward's value to that campaign's budget in the same transaction.
+// AmountINR records the amount a reward was requested in when Quantity was
+// converted from it; the rounding residue is posted to the ledger. APIKeyID
+// charges one use of that key's DailyQuota, only if the reward is booked.
+type RewardInput struct {
+	UserID           string
+	Symbol           string
+	Quantity         decimal.Decimal
+	Timestamp        time.Time
+	IdempotencyKey   string
+	Source           string
+	Price            decimal.Decimal
+	Limits           *policy.Limits
+	RequiresApproval bool
+	RequestedBy      string
+	Vesting          *vesting.Schedule
+	CampaignID       string
+	AmountINR        *decimal.Decimal
+	APIKeyID         string
+	DailyQuota       int
 }
 
-func (r *Repo) CreateReward(ctx context.Context, userID, symbol string, quantity decimal.Decimal, ts time.Time, idempotencyKey, source string, price decimal.Decimal) (string, bool, error) {
+func (r *Repo) CreateReward(ctx context.Context, in RewardInput) (string, bool, error) {
+	userID, symbol, quantity, ts, idempotencyKey, source, price := in.UserID, in.Symbol, in.Quantity, in.Timestamp, in.IdempotencyKey, in.Source, in.Price
+
 	var existingID sql.NullString
 	if idempotencyKey != "" {
 		err := r.db.GetContext(ctx, &existingID, "SELECT id FROM rewards WHERE idempotency_key = $1 LIMIT 1", idempotencyKey)
@@ -40,9 +96,50 @@ func (r *Repo) CreateReward(ctx context.Context, userID, symbol string, quantity
 		}
 	}()
 
+	// Daily limits and quotas reset at midnight in the server timezone.
+	today := calendar.DayStart(time.Now(), r.location)
+	if in.Limits != nil && in.Limits.Enforced() {
+		usage, err := rewardUsage(ctx, tx, userID, source, today)
+		if err != nil {
+			tx.Rollback()
+			return "", false, err
+		}
+		if err := in.Limits.Evaluate(usage, quantity.Mul(price)); err != nil {
+			tx.Rollback()
+			return "", false, err
+		}
+	}
+
+	if in.APIKeyID != "" {
+		if err := consumeAPIKeyQuota(ctx, tx, in.APIKeyID, today, in.DailyQuota); err != nil {
+			tx.Rollback()
+			return "", false, err
+		}
+	}
+
+	if in.CampaignID != "" {
+		if err := chargeCampaign(ctx, tx, in.CampaignID, userID, symbol, quantity.Mul(price), time.Now()); err != nil {
+			tx.Rollback()
+			return "", false, err
+		}
+	}
+
 	var rewardID string
-	q := `INSERT INTO rewards (id, user_id, symbol, quantity, timestamp, idempotency_key, source, created_at, status) VALUES (gen_random_uuid(), $1, $2, $3::numeric, $4, $5, $6, now(), 'COMPLETED') RETURNING id`
-	if err := tx.QueryRowContext(ctx, q, userID, symbol, quantity.String(), ts, idempotencyKey, source).Scan(&rewardID); err != nil {
+	status := StatusCompleted
+	if in.RequiresApproval {
+		status = StatusPendingApproval
+	}
+	var cliff, months *int
+	if in.Vesting != nil {
+		cliff, months = &in.Vesting.CliffMonths, &in.Vesting.Months
+	}
+	var amount *string
+	if in.AmountINR != nil {
+		a := in.AmountINR.StringFixed(4)
+		amount = &a
+	}
+	q := `INSERT INTO rewards (id, user_id, symbol, quantity, timestamp, idempotency_key, source, created_at, status, price_inr, vesting_cliff_months, vesting_months, campaign_id, requested_amount_inr) VALUES (gen_random_uuid(), $1, $2, $3::numeric, $4, $5, $6, now(), $7, $8::numeric, $9, $10, NULLIF($11, '')::uuid, $12::numeric) RETURNING id`
+	if err := tx.QueryRowContext(ctx, q, userID, symbol, quantity.String(), ts, idempotencyKey, source, status, price.StringFixed(4), cliff, months, in.CampaignID, amount).Scan(&rewardID); err != nil {
 		tx.Rollback()
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
 			var existing string
@@ -53,32 +150,33 @@ func (r *Repo) CreateReward(ctx context.Context, userID, symbol string, quantity
 		return "", false, err
 	}
 
+	if in.RequiresApproval {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO approvals (reward_id, requested_by) VALUES ($1, $2)`, rewardID, in.RequestedBy); err != nil {
+			tx.Rollback()
+			return "", false, err
+		}
+	}
 
-	amountINR := quantity.Mul(price)
-	fees := amountINR.Mul(decimal.NewFromFloat(0.01)).Round(4)
-	totalCashOut := amountINR.Add(fees)
-
-	ledgerQ := `INSERT INTO ledger_entries (id, reward_id, entry_time, account_debit, account_credit, amount_inr, stock_symbol, stock_quantity, description) VALUES (gen_random_uuid(), $1, now(), $2, $3, $4::numeric, $5, $6::numeric, $7)`
-	if _, err := tx.ExecContext(ctx, ledgerQ, rewardID, "company_cash", "stock_inventory", totalCashOut.StringFixed(4), symbol, quantity.StringFixed(6), "reward purchase"); err != nil {
+	priceQ := `INSERT INTO price_history (symbol, price_inr, timestamp) VALUES ($1, $2::numeric, $3)`
+	if _, err := tx.ExecContext(ctx, priceQ, symbol, price.StringFixed(4), ts); err != nil {
 		tx.Rollback()
 		return "", false, err
 	}
-	if fees.Cmp(decimal.Zero) > 0 {
-		if _, err := tx.ExecContext(ctx, ledgerQ, rewardID, "company_expense", "company_cash", fees.StringFixed(4), nil, nil, "fees for reward"); err != nil {
+
+	if !in.RequiresApproval {
+		b := bookedReward{ID: rewardID, UserID: userID, Symbol: symbol, Quantity: quantity, Price: price, Timestamp: ts, Source: source, Vesting: in.Vesting, AmountINR: in.AmountINR}
+		if err := creditReward(ctx, tx, b); err != nil {
 			tx.Rollback()
 			return "", false, err
 		}
 	}
 
-
-	upsert := `INSERT INTO holdings (user_id, symbol, quantity, last_updated) VALUES ($1, $2, $3::numeric, now()) ON CONFLICT (user_id, symbol) DO UPDATE SET quantity = holdings.quantity + $3::numeric, last_updated = now()`
-	if _, err := tx.ExecContext(ctx, upsert, userID, symbol, quantity.String()); err != nil {
+	after, err := rewardSnapshot(ctx, tx, rewardID)
+	if err != nil {
 		tx.Rollback()
 		return "", false, err
 	}
-
-	priceQ := `INSERT INTO price_history (symbol, price_inr, timestamp) VALUES ($1, $2::numeric, $3)`
-	if _, err := tx.ExecContext(ctx, priceQ, symbol, price.StringFixed(4), ts); err != nil {
+	if err := recordAudit(ctx, tx, AuditRewardCreate, "reward", rewardID, nil, after, ""); err != nil {
 		tx.Rollback()
 		return "", false, err
 	}
@@ -89,7 +187,11 @@ func (r *Repo) CreateReward(ctx context.Context, userID, symbol string, quantity
 	return rewardID, true, nil
 }
 
-func (r *Repo) ReverseReward(ctx context.Context, rewardID string) error {
+// ReverseReward takes a completed reward's shares back out of holdings and
+// records why, and who reversed it, on the reward. It returns sql.ErrNoRows
+// unless the reward is completed and ErrSharesNotHeld if fewer unreserved
+// shares are held than it credited.
+func (r *Repo) ReverseReward(ctx context.Context, rewardID string, rev Reversal) error {
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
@@ -105,13 +207,59 @@ func (r *Repo) ReverseReward(ctx context.Context, rewardID string) error {
 	if status != "COMPLETED" {
 		return sql.ErrNoRows
 	}
+	before, err := rewardSnapshot(ctx, tx, rewardID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE rewards SET status = 'REVERSED', reversed_at = now(), reversal_reason = $2, reversal_note = NULLIF($3, ''), reversed_by = $4 WHERE id = $1`,
+		rewardID, rev.Reason, rev.Note, rev.ReversedBy); err != nil {
+		return err
+	}
 
+	if err := refundCampaign(ctx, tx, rewardID); err != nil {
+		return err
+	}
 
-	if _, err := tx.ExecContext(ctx, `UPDATE rewards SET status = 'REVERSED' WHERE id = $1`, rewardID); err != nil {
+	// Only the vested part of a vesting grant ever reached holdings.
+	held := quantity
+	if _, vested, ok, err := closeVesting(ctx, tx, rewardID, VestingReversed, accountInventory, "reversed unvested shares"); err != nil {
 		return err
+	} else if ok {
+		held = vested
 	}
 
-	if _, err := tx.ExecContext(ctx, `UPDATE holdings SET quantity = quantity - $1::numeric, last_updated = now() WHERE user_id = $2 AND symbol = $3`, quantity.String(), userID, symbol); err != nil {
+	var qty, reserved decimal.Decimal
+	err = tx.QueryRowContext(ctx, `SELECT quantity, reserved_quantity FROM holdings WHERE user_id = $1 AND symbol = $2 FOR UPDATE`, userID, symbol).Scan(&qty, &reserved)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if qty.Sub(reserved).LessThan(held) {
+		return ErrSharesNotHeld
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE holdings SET quantity = quantity - $1::numeric, last_updated = now() WHERE user_id = $2 AND symbol = $3`, held.String(), userID, symbol); err != nil {
+		return err
+	}
+	if _, err := consumeLots(ctx, tx, userID, symbol, held, &rewardID); err != nil {
+		return err
+	}
+
+	if err := enqueueOutbox(ctx, tx, events.RewardReversed, map[string]interface{}{
+		"reward_id": rewardID,
+		"user_id":   userID,
+		"symbol":    symbol,
+		"quantity":  quantity.StringFixed(6),
+		"reason":    rev.Reason,
+	}); err != nil {
+		return err
+	}
+
+	after, err := rewardSnapshot(ctx, tx, rewardID)
+	if err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, tx, AuditRewardReverse, "reward", rewardID, before, after, rev.Reason); err != nil {
 		return err
 	}
 
@@ -125,10 +273,16 @@ type Reward struct {
 	Timestamp time.Time       `db:"timestamp" json:"timestamp"`
 }
 
+// GetTodayRewards returns the rewards granted since midnight in the user's
+// timezone.
 func (r *Repo) GetTodayRewards(ctx context.Context, userID string) ([]Reward, error) {
-	start := time.Now().UTC().Truncate(24 * time.Hour)
-	end := start.Add(24 * time.Hour)
-	rows, err := r.db.QueryxContext(ctx, `SELECT id, symbol, quantity, timestamp FROM rewards WHERE user_id = $1 AND timestamp >= $2 AND timestamp < $3 ORDER BY timestamp ASC`, userID, start, end)
+	loc, err := r.UserLocation(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	start := calendar.DayStart(time.Now(), loc)
+	end := start.AddDate(0, 0, 1)
+	rows, err := r.db.QueryxContext(ctx, `SELECT id, symbol, quantity, timestamp FROM rewards WHERE user_id = $1 AND timestamp >= $2 AND timestamp < $3 AND status NOT IN ('PENDING_APPROVAL', 'REJECTED') ORDER BY timestamp ASC`, userID, start, end)
 	if err != nil {
 		return nil, err
 	}
@@ -146,7 +300,7 @@ func (r *Repo) GetTodayRewards(ctx context.Context, userID string) ([]Reward, er
 }
 
 func (r *Repo) GetHoldings(ctx context.Context, userID string) ([]Holding, error) {
-	rows, err := r.db.QueryxContext(ctx, `SELECT symbol, quantity FROM holdings WHERE user_id = $1`, userID)
+	rows, err := r.db.QueryxContext(ctx, `SELECT symbol, quantity, reserved_quantity FROM holdings WHERE user_id = $1`, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -182,7 +336,7 @@ func (r *Repo) UpsertPrice(ctx context.Context, symbol string, price decimal.Dec
 }
 
 func (r *Repo) GetAllSymbols(ctx context.Context) ([]string, error) {
-	rows, err := r.db.QueryxContext(ctx, `SELECT symbol FROM stocks`)
+	rows, err := r.db.QueryxContext(ctx, `SELECT symbol FROM stocks WHERE active`)
 	if err != nil {
 		return nil, err
 	}
@@ -199,8 +353,40 @@ func (r *Repo) GetAllSymbols(ctx context.Context) ([]string, error) {
 	return res, nil
 }
 
-func (r *Repo) GetDailyValuations(ctx context.Context, userID string) ([]DailyValuation, error) {
-	rows, err := r.db.QueryxContext(ctx, `SELECT date, total_inr FROM daily_valuations WHERE user_id = $1 AND date < CURRENT_DATE ORDER BY date ASC`, userID)
+// GetDailyValuations returns the user's end-of-day values for q's range,
+// from stored snapshots when there are any and q needs no per-symbol
+// breakdown, otherwise computed from the ledger. Values are resampled to
+// q.Granularity and, with q.IncludeToday, end with today's intraday value.
+func (r *Repo) GetDailyValuations(ctx context.Context, userID string, q ValuationQuery) ([]DailyValuation, error) {
+	if q.Location == nil {
+		loc, err := r.UserLocation(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		q.Location = loc
+	}
+	res, err := r.dailyValuations(ctx, userID, q)
+	if err != nil {
+		return nil, err
+	}
+	res = Resample(res, q.Granularity)
+	if q.IncludeToday && (q.To.IsZero() || q.To.Format("2006-01-02") >= calendar.Date(time.Now(), q.Location)) {
+		today, err := r.intradayValuation(ctx, userID, q)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, today)
+	}
+	return res, nil
+}
+
+func (r *Repo) dailyValuations(ctx context.Context, userID string, q ValuationQuery) ([]DailyValuation, error) {
+	if q.BySymbol {
+		return r.ComputeHistoricalValuations(ctx, userID, q)
+	}
+	rows, err := r.db.QueryxContext(ctx, `SELECT date::text, total_inr FROM daily_valuations WHERE user_id = $1 AND date < $4::date
+		AND ($2::date IS NULL OR date >= $2::date) AND ($3::date IS NULL OR date <= $3::date) ORDER BY date ASC`,
+		userID, nullDate(q.From), nullDate(q.To), calendar.Date(time.Now(), q.Location))
 	if err == nil {
 		defer rows.Close()
 		res := []DailyValuation{}
@@ -220,30 +406,84 @@ func (r *Repo) GetDailyValuations(ctx context.Context, userID string) ([]DailyVa
 		}
 	}
 
-	return r.ComputeHistoricalValuations(ctx, userID)
+	return r.ComputeHistoricalValuations(ctx, userID, q)
+}
+
+// intradayValuation values today's holdings at the latest prices.
+func (r *Repo) intradayValuation(ctx context.Context, userID string, q ValuationQuery) (DailyValuation, error) {
+	items, total, err := r.GetPortfolio(ctx, userID)
+	if err != nil {
+		return DailyValuation{}, err
+	}
+	v := DailyValuation{Date: calendar.Date(time.Now(), q.Location), TotalINR: total, Intraday: true}
+	if q.BySymbol {
+		v.Symbols = map[string]decimal.Decimal{}
+		for _, it := range items {
+			if !it.CurrentValue.IsZero() {
+				v.Symbols[it.Symbol] = it.CurrentValue
+			}
+		}
+	}
+	return v, nil
 }
 
-func (r *Repo) ComputeHistoricalValuations(ctx context.Context, userID string) ([]DailyValuation, error) {
+// ComputeHistoricalValuations values the user's holdings at the end of each
+// day in q's range, up to yesterday, ignoring q.Granularity. Days end at
+// midnight in q.Location, or in the user's timezone if it is nil.
+func (r *Repo) ComputeHistoricalValuations(ctx context.Context, userID string, q ValuationQuery) ([]DailyValuation, error) {
+	loc := q.Location
+	if loc == nil {
+		var err error
+		if loc, err = r.UserLocation(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
 	var minDate sql.NullTime
-	if err := r.db.GetContext(ctx, &minDate, `SELECT MIN(timestamp) FROM rewards WHERE user_id = $1`, userID); err != nil {
+	if err := r.db.GetContext(ctx, &minDate, `
+		SELECT MIN(t) FROM (
+			SELECT timestamp AS t FROM rewards WHERE user_id = $1
+			UNION ALL SELECT created_at FROM transfers WHERE to_user_id = $1
+		) x`, userID); err != nil {
 		return nil, err
 	}
 	if !minDate.Valid {
 		return []DailyValuation{}, nil
 	}
-	start := minDate.Time.UTC().Truncate(24 * time.Hour)
-	end := time.Now().UTC().Truncate(24 * time.Hour).Add(-24 * time.Hour)
+	start := calendar.DayStart(minDate.Time, loc)
+	end := calendar.DayStart(time.Now(), loc).AddDate(0, 0, -1)
+	if from := localDate(q.From, loc); !q.From.IsZero() && from.After(start) {
+		start = from
+	}
+	if to := localDate(q.To, loc); !q.To.IsZero() && to.Before(end) {
+		end = to
+	}
 	if !start.Before(end) && !start.Equal(end) {
 		return []DailyValuation{}, nil
 	}
+	grants, err := r.vestingGrants(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
 	res := []DailyValuation{}
-	for d := start; !d.After(end); d = d.Add(24 * time.Hour) {
-		targetTS := d.Add(24 * time.Hour).Add(-1 * time.Microsecond)
-		
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		targetTS := d.AddDate(0, 0, 1).Add(-1 * time.Microsecond)
+		// Prefer the last trading close; fall back to any quote that day.
+		priceTS := targetTS
+		if r.calendar != nil {
+			if cl := r.calendar.LastClose(targetTS); !cl.IsZero() {
+				priceTS = cl
+			}
+		}
+
 		rows, err := r.db.QueryxContext(ctx, `
-			SELECT symbol, COALESCE(SUM(quantity)::text,'0') AS qty 
-			FROM rewards 
-			WHERE user_id = $1 AND timestamp <= $2 AND status = 'COMPLETED' 
+			SELECT symbol, COALESCE(SUM(qty)::text,'0') AS qty
+			FROM (
+				SELECT symbol, quantity AS qty FROM rewards WHERE user_id = $1 AND timestamp <= $2 AND status = 'COMPLETED'
+				UNION ALL SELECT symbol, quantity FROM transfers WHERE to_user_id = $1 AND created_at <= $2
+				UNION ALL SELECT symbol, -quantity FROM transfers WHERE from_user_id = $1 AND created_at <= $2
+				UNION ALL SELECT symbol, -quantity FROM sales WHERE user_id = $1 AND created_at <= $2
+				UNION ALL SELECT symbol, -quantity FROM withdrawals WHERE user_id = $1 AND status = 'SETTLED' AND updated_at <= $2
+			) q
 			GROUP BY symbol`, userID, targetTS)
 		if err != nil {
 			r.log.Warnf("get cumulative quantities failed for %v: %v", d, err)
@@ -251,6 +491,10 @@ func (r *Repo) ComputeHistoricalValuations(ctx context.Context, userID string) (
 		}
 		
 		var total decimal.Decimal
+		var symbols map[string]decimal.Decimal
+		if q.BySymbol {
+			symbols = map[string]decimal.Decimal{}
+		}
 		for rows.Next() {
 			var sym string
 			var qtyStr string
@@ -258,6 +502,11 @@ func (r *Repo) ComputeHistoricalValuations(ctx context.Context, userID string) (
 				continue
 			}
 			qty, _ := decimal.NewFromString(qtyStr)
+			for _, g := range grants {
+				if g.Symbol == sym && !g.Timestamp.After(targetTS) {
+					qty = qty.Sub(g.Quantity.Sub(g.heldAt(targetTS)))
+				}
+			}
 			if qty.IsZero() {
 				continue
 			}
@@ -267,11 +516,14 @@ func (r *Repo) ComputeHistoricalValuations(ctx context.Context, userID string) (
 				SELECT price_inr 
 				FROM price_history 
 				WHERE symbol = $1 AND timestamp <= $2 
-				ORDER BY timestamp DESC LIMIT 1`, sym, targetTS)
+				ORDER BY (timestamp <= $3) DESC, timestamp DESC LIMIT 1`, sym, targetTS, priceTS)
 			
 			if err == nil && priceStr.Valid {
 				p, _ := decimal.NewFromString(priceStr.String)
 				total = total.Add(qty.Mul(p))
+				if symbols != nil {
+					symbols[sym] = qty.Mul(p)
+				}
 			}
 		}
 		rows.Close()
@@ -279,6 +531,7 @@ func (r *Repo) ComputeHistoricalValuations(ctx context.Context, userID string) (
 		res = append(res, DailyValuation{
 			Date:     d.Format("2006-01-02"), 
 			TotalINR: total,
+			Symbols:  symbols,
 		})
 	}
 	return res, nil
@@ -289,6 +542,23 @@ func (r *Repo) GetPortfolio(ctx context.Context, userID string) ([]PortfolioItem
 	if err != nil {
 		return nil, decimal.Zero, err
 	}
+	unvested, err := r.unvestedBySymbol(ctx, userID)
+	if err != nil {
+		return nil, decimal.Zero, err
+	}
+	lots, err := r.openLots(ctx, userID)
+	if err != nil {
+		return nil, decimal.Zero, err
+	}
+	held := map[string]bool{}
+	for _, h := range holdings {
+		held[h.Symbol] = true
+	}
+	for sym := range unvested {
+		if !held[sym] {
+			holdings = append(holdings, Holding{Symbol: sym})
+		}
+	}
 	items := []PortfolioItem{}
 	total := decimal.Zero
 	for _, h := range holdings {
@@ -298,18 +568,69 @@ func (r *Repo) GetPortfolio(ctx context.Context, userID string) ([]PortfolioItem
 			continue
 		}
 		value := h.Quantity.Mul(price)
-		items = append(items, PortfolioItem{Symbol: h.Symbol, Quantity: h.Quantity, CurrentPrice: price, CurrentValue: value})
+		uq := unvested[h.Symbol]
+		invested := decimal.Zero
+		for _, l := range lots[h.Symbol] {
+			invested = invested.Add(l.RemainingQuantity.Mul(l.CostPerShareINR))
+		}
+		invested = invested.Round(4)
+		avg := decimal.Zero
+		if h.Quantity.IsPositive() {
+			avg = invested.DivRound(h.Quantity, 4)
+		}
+		symLots := lots[h.Symbol]
+		if symLots == nil {
+			symLots = []Lot{}
+		}
+		items = append(items, PortfolioItem{
+			Symbol:           h.Symbol,
+			Quantity:         h.Quantity,
+			VestedQuantity:   h.Quantity,
+			UnvestedQuantity: uq,
+			ReservedQuantity: h.Reserved,
+			CurrentPrice:     price,
+			CurrentValue:     value,
+			UnvestedValue:    uq.Mul(price),
+			InvestedINR:      invested,
+			AverageCostINR:   avg,
+			UnrealisedINR:    value.Sub(invested),
+			UnrealisedPct:    GainPct(value.Sub(invested), invested),
+			Lots:             symLots,
+		})
 		total = total.Add(value)
 	}
 	return items, total, nil
 }
 
-func (r *Repo) EnsureStockExists(ctx context.Context, symbol, name string) error {
-	_, err := r.db.ExecContext(ctx, `INSERT INTO stocks (symbol, name) VALUES ($1, $2) ON CONFLICT (symbol) DO NOTHING`, symbol, name)
-	return err
+func (r *Repo) EnsureUserExists(ctx context.Context, userID, name string) error {
+	return r.ensureExists(ctx, AuditUserCreate, "user", userID,
+		`INSERT INTO users (id, name) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING RETURNING row_to_json(users)`, userID, name)
 }
 
-func (r *Repo) EnsureUserExists(ctx context.Context, userID, name string) error {
-	_, err := r.db.ExecContext(ctx, `INSERT INTO users (id, name) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING`, userID, name)
-	return err
+// ensureExists runs an insert that does nothing on conflict and audits the
+// new row if one was created.
+func (r *Repo) ensureExists(ctx context.Context, action, entityType, entityID, q string, args ...interface{}) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var after []byte
+	if err := tx.QueryRowContext(ctx, q, args...).Scan(&after); errors.Is(err, sql.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, tx, action, entityType, entityID, nil, after, ""); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Repo) GetRewardOwner(ctx context.Context, rewardID string) (string, error) {
+	var userID string
+	if err := r.db.GetContext(ctx, &userID, `SELECT user_id FROM rewards WHERE id = $1`, rewardID); err != nil {
+		return "", err
+	}
+	return userID, nil
 }
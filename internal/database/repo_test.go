@@ -2,8 +2,11 @@ package database
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 	"testing"
 	"time"
 
@@ -23,7 +26,11 @@ func setupDB(t *testing.T) *sqlx.DB {
 		t.Fatalf("open db: %v", err)
 	}
 
-	files := []string{"../../migrations/0001_init.up.sql"}
+	files, err := filepath.Glob("../../migrations/*.up.sql")
+	if err != nil {
+		t.Fatalf("list migrations: %v", err)
+	}
+	sort.Strings(files)
 	for _, f := range files {
 		b, err := ioutil.ReadFile(f)
 		if err != nil {
@@ -52,7 +59,7 @@ func TestCreateReward_Idempotency(t *testing.T) {
 	}
 
 
-	id1, created, err := r.CreateReward(context.Background(), userID, symbol, q, time.Now().UTC(), idKey, "test", decimal.NewFromFloat(100))
+	id1, created, err := r.CreateReward(context.Background(), RewardInput{UserID: userID, Symbol: symbol, Quantity: q, Timestamp: time.Now().UTC(), IdempotencyKey: idKey, Source: "test", Price: decimal.NewFromFloat(100)})
 	if err != nil {
 		t.Fatalf("create reward failed: %v", err)
 	}
@@ -64,7 +71,7 @@ func TestCreateReward_Idempotency(t *testing.T) {
 	}
 
 
-	id2, created2, err := r.CreateReward(context.Background(), userID, symbol, q, time.Now().UTC(), idKey, "test", decimal.NewFromFloat(100))
+	id2, created2, err := r.CreateReward(context.Background(), RewardInput{UserID: userID, Symbol: symbol, Quantity: q, Timestamp: time.Now().UTC(), IdempotencyKey: idKey, Source: "test", Price: decimal.NewFromFloat(100)})
 	if err != nil {
 		t.Fatalf("create reward (replay) failed: %v", err)
 	}
@@ -84,4 +91,21 @@ func TestCreateReward_Idempotency(t *testing.T) {
 	if !qty.Equal(q) {
 		t.Logf("expected holdings %s, got %s (might be cumulative from other runs)", q.StringFixed(6), qty.StringFixed(6))
 	}
-}
\ No newline at end of file
+}
+
+func TestCreateRewardRejectsNonPositiveQuantity(t *testing.T) {
+	db := setupDB(t)
+	r := New(db, logrus.New())
+
+	userID := "test-nonpositive-user"
+	if _, err := db.Exec("INSERT INTO users (id, name) VALUES ($1, $1) ON CONFLICT (id) DO NOTHING", userID); err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	for i, q := range []int64{0, -5} {
+		_, _, err := r.CreateReward(context.Background(), RewardInput{UserID: userID, Symbol: "TCS", Quantity: decimal.NewFromInt(q), Timestamp: time.Now().UTC(),
+			IdempotencyKey: fmt.Sprintf("test-nonpositive-%d-%d", time.Now().UnixNano(), i), Source: "test", Price: decimal.NewFromInt(100)})
+		if err == nil {
+			t.Errorf("quantity %d should be rejected", q)
+		}
+	}
+}
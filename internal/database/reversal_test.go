@@ -2,6 +2,8 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"testing"
 	"time"
 
@@ -28,7 +30,7 @@ func TestReverseReward(t *testing.T) {
 	_, _ = db.Exec("DELETE FROM holdings WHERE user_id = $1 AND symbol = $2", userID, symbol)
 
 
-	id, created, err := r.CreateReward(context.Background(), userID, symbol, q, time.Now().UTC(), idKey, "test", decimal.NewFromFloat(100))
+	id, created, err := r.CreateReward(context.Background(), RewardInput{UserID: userID, Symbol: symbol, Quantity: q, Timestamp: time.Now().UTC(), IdempotencyKey: idKey, Source: "test", Price: decimal.NewFromFloat(100)})
 	if err != nil {
 		t.Fatalf("create reward failed: %v", err)
 	}
@@ -46,7 +48,7 @@ func TestReverseReward(t *testing.T) {
 	}
 
 
-	if err := r.ReverseReward(context.Background(), id); err != nil {
+	if err := r.ReverseReward(context.Background(), id, Reversal{Reason: ReversalOpsError, Note: "booked to the wrong user", ReversedBy: "test-admin"}); err != nil {
 		t.Fatalf("reverse reward failed: %v", err)
 	}
 
@@ -60,12 +62,57 @@ func TestReverseReward(t *testing.T) {
 	}
 
 
-	var status string
-	err = db.QueryRow("SELECT status FROM rewards WHERE id = $1", id).Scan(&status)
+	var status, reason, note, by string
+	err = db.QueryRow("SELECT status, reversal_reason, reversal_note, reversed_by FROM rewards WHERE id = $1", id).Scan(&status, &reason, &note, &by)
 	if err != nil {
 		t.Fatalf("get status failed: %v", err)
 	}
 	if status != "REVERSED" {
 		t.Fatalf("expected status REVERSED, got %s", status)
 	}
+	if reason != ReversalOpsError || note != "booked to the wrong user" || by != "test-admin" {
+		t.Fatalf("expected the reversal reason, note and operator to be stored, got %q %q %q", reason, note, by)
+	}
+}
+
+func TestReverseRewardRequiresHeldShares(t *testing.T) {
+	db := setupDB(t)
+	r := New(db, logrus.New())
+	ctx := context.Background()
+
+	userID, symbol, idKey := "test-reverse-sold-user", "INFY", "test-reverse-sold-key"
+	if _, err := db.Exec("INSERT INTO users (id, name) VALUES ($1, $1) ON CONFLICT (id) DO NOTHING", userID); err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	_, _ = db.Exec("DELETE FROM ledger_entries WHERE reward_id IN (SELECT id FROM rewards WHERE idempotency_key = $1)", idKey)
+	_, _ = db.Exec("DELETE FROM rewards WHERE idempotency_key = $1", idKey)
+	_, _ = db.Exec("DELETE FROM holdings WHERE user_id = $1", userID)
+
+	id, _, err := r.CreateReward(ctx, RewardInput{UserID: userID, Symbol: symbol, Quantity: decimal.NewFromInt(4), Timestamp: time.Now().UTC(), IdempotencyKey: idKey, Source: "test", Price: decimal.NewFromInt(1500)})
+	if err != nil {
+		t.Fatalf("create reward failed: %v", err)
+	}
+	// Two of the four shares are reserved for a withdrawal.
+	if _, err := db.Exec("UPDATE holdings SET reserved_quantity = 2 WHERE user_id = $1 AND symbol = $2", userID, symbol); err != nil {
+		t.Fatalf("reserve shares failed: %v", err)
+	}
+
+	rev := Reversal{Reason: ReversalFraud, Note: "test", ReversedBy: "test-admin"}
+	if err := r.ReverseReward(ctx, id, rev); !errors.Is(err, ErrSharesNotHeld) {
+		t.Fatalf("expected ErrSharesNotHeld, got %v", err)
+	}
+	var status string
+	if err := db.Get(&status, "SELECT status FROM rewards WHERE id = $1", id); err != nil || status != StatusCompleted {
+		t.Fatalf("refused reversal should leave the reward completed, got %q (err %v)", status, err)
+	}
+
+	if _, err := db.Exec("UPDATE holdings SET reserved_quantity = 0 WHERE user_id = $1 AND symbol = $2", userID, symbol); err != nil {
+		t.Fatalf("release shares failed: %v", err)
+	}
+	if err := r.ReverseReward(ctx, id, rev); err != nil {
+		t.Fatalf("reverse reward failed: %v", err)
+	}
+	if err := r.ReverseReward(ctx, id, rev); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("second reversal should return sql.ErrNoRows, got %v", err)
+	}
 }
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Reasons a reward may be reversed for.
+const (
+	ReversalFraud       = "fraud"
+	ReversalDuplicate   = "duplicate"
+	ReversalOpsError    = "ops_error"
+	ReversalUserRequest = "user_request"
+)
+
+// ErrSharesNotHeld means a reward's shares have since been sold, transferred
+// or reserved for a withdrawal, so reversing it would leave holdings negative.
+var ErrSharesNotHeld = errors.New("reward shares are no longer held")
+
+var ReversalReasons = []string{ReversalFraud, ReversalDuplicate, ReversalOpsError, ReversalUserRequest}
+
+func ValidReversalReason(reason string) bool {
+	for _, r := range ReversalReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Reversal is why a reward is being reversed and the operator doing it.
+type Reversal struct {
+	Reason     string
+	Note       string
+	ReversedBy string
+}
+
+// ReversalSummary totals the reversals made for one reason. Reversals made
+// before reasons were recorded are grouped under an empty Reason.
+type ReversalSummary struct {
+	Reason   string          `db:"reason" json:"reason"`
+	Count    int             `db:"count" json:"count"`
+	Users    int             `db:"users" json:"users"`
+	ValueINR decimal.Decimal `db:"value_inr" json:"value_inr"`
+}
+
+// ReversalReport groups the rewards reversed between from (inclusive) and
+// to (exclusive) by reason, largest value first. Zero times leave the range
+// open and an empty source matches every source. ValueINR is at booking
+// price.
+func (r *Repo) ReversalReport(ctx context.Context, from, to time.Time, source string) ([]ReversalSummary, error) {
+	res := []ReversalSummary{}
+	err := r.db.SelectContext(ctx, &res, `
+		SELECT COALESCE(reversal_reason, '') AS reason, COUNT(*) AS count, COUNT(DISTINCT user_id) AS users,
+			COALESCE(SUM(quantity * price_inr), 0) AS value_inr
+		FROM rewards
+		WHERE status = 'REVERSED'
+		  AND ($1::timestamptz IS NULL OR reversed_at >= $1)
+		  AND ($2::timestamptz IS NULL OR reversed_at < $2)
+		  AND ($3 = '' OR source = $3)
+		GROUP BY 1
+		ORDER BY value_inr DESC, reason`, nullTime(from), nullTime(to), source)
+	return res, err
+}
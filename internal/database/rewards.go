@@ -0,0 +1,231 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"stocky/internal/events"
+	"stocky/internal/models"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrBadCursor = errors.New("invalid cursor")
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ValidUUID reports whether s is a UUID in its canonical hyphenated form.
+func ValidUUID(s string) bool {
+	return uuidPattern.MatchString(s)
+}
+
+const rewardColumns = `id, user_id, symbol, quantity, timestamp, status, source, idempotency_key, price_inr, quantity * price_inr AS value_inr,
+	requested_amount_inr, campaign_id, vesting_cliff_months, vesting_months, COALESCE(created_at, timestamp) AS created_at, reversed_at,
+	reversal_reason, reversal_note, reversed_by`
+
+// RewardCursor marks the last reward of a page; the next page starts after
+// it in (timestamp, id) descending order.
+type RewardCursor struct {
+	Timestamp time.Time
+	ID        string
+}
+
+func (c RewardCursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.Timestamp.UTC().Format(time.RFC3339Nano) + "|" + c.ID))
+}
+
+func DecodeRewardCursor(s string) (RewardCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return RewardCursor{}, ErrBadCursor
+	}
+	ts, id, ok := strings.Cut(string(b), "|")
+	if !ok || !ValidUUID(id) {
+		return RewardCursor{}, ErrBadCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return RewardCursor{}, ErrBadCursor
+	}
+	return RewardCursor{Timestamp: t, ID: id}, nil
+}
+
+// RewardFilter selects rewards for ListRewards. Empty fields match
+// everything; From is inclusive and To exclusive. An empty Status matches
+// completed and reversed rewards, and pending and rejected ones too if
+// IncludePending is set.
+type RewardFilter struct {
+	UserID         string
+	Symbol         string
+	Status         string
+	Source         string
+	IdempotencyKey string
+	ReversalReason string
+	From           time.Time
+	To             time.Time
+	IncludePending bool
+	After          *RewardCursor
+	Limit          int
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// ListRewards returns up to f.Limit rewards, newest first, and the cursor of
+// the next page, or nil if this is the last one.
+func (r *Repo) ListRewards(ctx context.Context, f RewardFilter) ([]models.Reward, *RewardCursor, error) {
+	var afterTS *time.Time
+	var afterID *string
+	if f.After != nil {
+		afterTS, afterID = &f.After.Timestamp, &f.After.ID
+	}
+	res := []models.Reward{}
+	err := r.db.SelectContext(ctx, &res, `SELECT `+rewardColumns+` FROM rewards
+		WHERE ($1 = '' OR user_id = $1)
+			AND ($2 = '' OR symbol = $2)
+			AND (($3 = '' AND ($10 OR status IN ('COMPLETED', 'REVERSED'))) OR status = $3)
+			AND ($4 = '' OR source = $4)
+			AND ($11 = '' OR idempotency_key = $11)
+			AND ($12 = '' OR reversal_reason = $12)
+			AND ($5::timestamptz IS NULL OR timestamp >= $5)
+			AND ($6::timestamptz IS NULL OR timestamp < $6)
+			AND ($7::timestamptz IS NULL OR (timestamp, id) < ($7, $8::uuid))
+		ORDER BY timestamp DESC, id DESC
+		LIMIT $9`,
+		f.UserID, f.Symbol, f.Status, f.Source, nullTime(f.From), nullTime(f.To), afterTS, afterID, f.Limit+1, f.IncludePending, f.IdempotencyKey, f.ReversalReason)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(res) <= f.Limit {
+		return res, nil, nil
+	}
+	res = res[:f.Limit]
+	last := res[len(res)-1]
+	return res, &RewardCursor{Timestamp: last.Timestamp, ID: last.ID}, nil
+}
+
+type LedgerEntry struct {
+	ID            string              `db:"id" json:"id"`
+	EntryTime     time.Time           `db:"entry_time" json:"entry_time"`
+	AccountDebit  string              `db:"account_debit" json:"account_debit"`
+	AccountCredit string              `db:"account_credit" json:"account_credit"`
+	AmountINR     decimal.Decimal     `db:"amount_inr" json:"amount_inr"`
+	StockSymbol   *string             `db:"stock_symbol" json:"stock_symbol,omitempty"`
+	StockQuantity decimal.NullDecimal `db:"stock_quantity" json:"stock_quantity"`
+	Description   *string             `db:"description" json:"description,omitempty"`
+}
+
+// RewardEvent is one step in a reward's life. Audited changes (create,
+// approve, reject, reverse) carry who made them and why, with the reward as
+// it stood afterwards as the payload; vesting and lapsing are read from the
+// events queued for them and have no actor.
+type RewardEvent struct {
+	Type    string          `db:"event_type" json:"type"`
+	Time    time.Time       `db:"created_at" json:"time"`
+	Actor   string          `db:"actor" json:"actor,omitempty"`
+	Reason  string          `db:"reason" json:"reason,omitempty"`
+	Payload json.RawMessage `db:"payload" json:"payload"`
+}
+
+// HoldingsEffect is what a reward did to the user's holdings. Credited
+// shares reached holdings, directly or by vesting; NetQuantity is what the
+// reward still contributes after a reversal. Lots shows how much of it the
+// user still holds after sells and transfers.
+type HoldingsEffect struct {
+	CreditedQuantity decimal.Decimal `json:"credited_quantity"`
+	UnvestedQuantity decimal.Decimal `json:"unvested_quantity"`
+	LapsedQuantity   decimal.Decimal `json:"lapsed_quantity"`
+	ReversedQuantity decimal.Decimal `json:"reversed_quantity"`
+	NetQuantity      decimal.Decimal `json:"net_quantity"`
+	Lots             []Lot           `json:"lots"`
+}
+
+type RewardDetail struct {
+	Reward   models.Reward  `json:"reward"`
+	Ledger   []LedgerEntry  `json:"ledger_entries"`
+	Holdings HoldingsEffect `json:"holdings_effect"`
+	History  []RewardEvent  `json:"history"`
+	Approval *Approval      `json:"approval,omitempty"`
+}
+
+// GetRewardDetail gathers everything support needs about one reward. It
+// returns sql.ErrNoRows for unknown ids, malformed ones included.
+func (r *Repo) GetRewardDetail(ctx context.Context, rewardID string) (RewardDetail, error) {
+	var d RewardDetail
+	if !ValidUUID(rewardID) {
+		return d, sql.ErrNoRows
+	}
+	if err := r.db.GetContext(ctx, &d.Reward, `SELECT `+rewardColumns+` FROM rewards WHERE id = $1`, rewardID); err != nil {
+		return d, err
+	}
+	rw := d.Reward
+
+	d.Ledger = []LedgerEntry{}
+	if err := r.db.SelectContext(ctx, &d.Ledger, `SELECT id, entry_time, account_debit, account_credit, amount_inr, stock_symbol, stock_quantity, description
+		FROM ledger_entries WHERE reward_id = $1 ORDER BY entry_time, id`, rewardID); err != nil {
+		return d, err
+	}
+
+	d.History = []RewardEvent{}
+	if err := r.db.SelectContext(ctx, &d.History, `
+		SELECT action AS event_type, created_at, actor, reason, after::jsonb AS payload
+		FROM audit_events WHERE entity_type = 'reward' AND entity_id = $1
+		UNION ALL
+		SELECT event_type, created_at, '', '', payload
+		FROM webhook_outbox WHERE payload->>'reward_id' = $1 AND event_type IN ($2, $3)
+		ORDER BY created_at`, rewardID, events.RewardVested, events.RewardLapsed); err != nil {
+		return d, err
+	}
+
+	var approval Approval
+	err := r.db.GetContext(ctx, &approval, `
+		SELECT a.id, a.reward_id, rw.user_id, rw.symbol, rw.quantity, rw.price_inr, rw.quantity * rw.price_inr AS value_inr,
+			rw.source, a.status, a.requested_by, a.decided_by, a.note, a.created_at, a.decided_at
+		FROM approvals a
+		JOIN rewards rw ON rw.id = a.reward_id
+		WHERE a.reward_id = $1`, rewardID)
+	if err == nil {
+		d.Approval = &approval
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return d, err
+	}
+
+	eff := HoldingsEffect{Lots: []Lot{}}
+	if rw.Status == StatusCompleted || rw.Status == StatusReversed {
+		eff.CreditedQuantity = rw.Quantity
+		var vested, lapsed decimal.Decimal
+		var status string
+		err := r.db.QueryRowContext(ctx, `SELECT vested_quantity, lapsed_quantity, status FROM reward_vesting WHERE reward_id = $1`, rewardID).Scan(&vested, &lapsed, &status)
+		switch {
+		case err == nil:
+			eff.CreditedQuantity = vested
+			switch status {
+			case VestingActive:
+				eff.UnvestedQuantity = rw.Quantity.Sub(vested)
+			case VestingLapsed:
+				eff.LapsedQuantity = lapsed
+			}
+		case !errors.Is(err, sql.ErrNoRows):
+			return d, err
+		}
+		if rw.Status == StatusReversed {
+			eff.ReversedQuantity = eff.CreditedQuantity
+		}
+		eff.NetQuantity = eff.CreditedQuantity.Sub(eff.ReversedQuantity)
+	}
+	if err := r.db.SelectContext(ctx, &eff.Lots, `SELECT `+lotColumns+` FROM holding_lots WHERE source_type = $2 AND source_id = $1 ORDER BY acquired_at, id`, rewardID, LotReward); err != nil {
+		return d, err
+	}
+	d.Holdings = eff
+	return d, nil
+}
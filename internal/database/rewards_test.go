@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRewardCursorRoundTrip(t *testing.T) {
+	c := RewardCursor{Timestamp: time.Date(2025, 3, 9, 20, 30, 0, 123456000, time.UTC), ID: "0b7c0f5e-2f0c-4d8e-9a8e-6f9d3c1b2a10"}
+	got, err := DecodeRewardCursor(c.Encode())
+	if err != nil || !got.Timestamp.Equal(c.Timestamp) || got.ID != c.ID {
+		t.Fatalf("round trip = %+v, %v; want %+v", got, err, c)
+	}
+	notUUID := RewardCursor{Timestamp: c.Timestamp, ID: "42"}.Encode()
+	for _, bad := range []string{"", "!!", "bm90LWEtY3Vyc29y", c.Encode()[:5], notUUID} {
+		if _, err := DecodeRewardCursor(bad); err == nil {
+			t.Errorf("DecodeRewardCursor(%q) should fail", bad)
+		}
+	}
+}
+
+func TestListRewardsPagination(t *testing.T) {
+	db := setupDB(t)
+	r := New(db, logrus.New())
+	ctx := context.Background()
+
+	user := "test-list-rewards-user"
+	if _, err := db.Exec("INSERT INTO users (id, name) VALUES ($1, $1) ON CONFLICT (id) DO NOTHING", user); err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	_, _ = db.Exec("DELETE FROM ledger_entries WHERE reward_id IN (SELECT id FROM rewards WHERE user_id = $1)", user)
+	_, _ = db.Exec("DELETE FROM holding_lots WHERE user_id = $1", user)
+	_, _ = db.Exec("DELETE FROM rewards WHERE user_id = $1", user)
+
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	ids := []string{}
+	for i := 0; i < 5; i++ {
+		symbol := "TCS"
+		if i%2 == 1 {
+			symbol = "INFY"
+		}
+		id, _, err := r.CreateReward(ctx, RewardInput{UserID: user, Symbol: symbol, Quantity: decimal.NewFromInt(1), Timestamp: base.Add(time.Duration(i) * time.Minute),
+			IdempotencyKey: fmt.Sprintf("test-list-rewards-%d", i), Source: "test", Price: decimal.NewFromInt(100)})
+		if err != nil {
+			t.Fatalf("create reward failed: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := r.ReverseReward(ctx, ids[4], Reversal{Reason: ReversalDuplicate, Note: "test", ReversedBy: "test-admin"}); err != nil {
+		t.Fatalf("reverse failed: %v", err)
+	}
+
+	seen := []string{}
+	f := RewardFilter{UserID: user, Limit: 2}
+	for page := 0; page < 5; page++ {
+		rows, next, err := r.ListRewards(ctx, f)
+		if err != nil {
+			t.Fatalf("list rewards failed: %v", err)
+		}
+		for _, rw := range rows {
+			seen = append(seen, rw.ID)
+		}
+		if next == nil {
+			break
+		}
+		f.After = next
+	}
+	if len(seen) != 5 || seen[0] != ids[4] || seen[4] != ids[0] {
+		t.Fatalf("expected all 5 rewards newest first, got %v (ids %v)", seen, ids)
+	}
+
+	rows, _, err := r.ListRewards(ctx, RewardFilter{UserID: user, Status: StatusReversed, Limit: 10})
+	if err != nil || len(rows) != 1 || rows[0].ReversedAt == nil || !rows[0].ValueINR.Valid {
+		t.Fatalf("expected the reversed reward with its reversal time and value, got %+v (err %v)", rows, err)
+	}
+	if rows[0].ReversalReason == nil || *rows[0].ReversalReason != ReversalDuplicate || rows[0].ReversedBy == nil || *rows[0].ReversedBy != "test-admin" {
+		t.Fatalf("expected the reversal reason and operator in the history, got %+v", rows[0])
+	}
+	rows, _, err = r.ListRewards(ctx, RewardFilter{UserID: user, ReversalReason: ReversalFraud, Limit: 10})
+	if err != nil || len(rows) != 0 {
+		t.Fatalf("expected no rewards reversed for fraud, got %+v (err %v)", rows, err)
+	}
+	report, err := r.ReversalReport(ctx, base, time.Time{}, "test")
+	if err != nil {
+		t.Fatalf("reversal report failed: %v", err)
+	}
+	found := false
+	for _, s := range report {
+		found = found || (s.Reason == ReversalDuplicate && s.Count >= 1 && s.ValueINR.IsPositive())
+	}
+	if !found {
+		t.Fatalf("expected the duplicate reversal in the report, got %+v", report)
+	}
+	rows, _, err = r.ListRewards(ctx, RewardFilter{UserID: user, Symbol: "INFY", To: base.Add(2 * time.Minute), Limit: 10})
+	if err != nil || len(rows) != 1 || rows[0].ID != ids[1] {
+		t.Fatalf("expected the INFY reward before the cut-off, got %+v (err %v)", rows, err)
+	}
+
+	rows, _, err = r.ListRewards(ctx, RewardFilter{IdempotencyKey: "test-list-rewards-2", IncludePending: true, Limit: 10})
+	if err != nil || len(rows) != 1 || rows[0].ID != ids[2] {
+		t.Fatalf("expected lookup by idempotency key to find reward 2, got %+v (err %v)", rows, err)
+	}
+
+	d, err := r.GetRewardDetail(ctx, ids[4])
+	if err != nil {
+		t.Fatalf("get reward detail failed: %v", err)
+	}
+	if len(d.Ledger) == 0 || len(d.History) < 2 || len(d.Holdings.Lots) != 1 {
+		t.Fatalf("expected ledger entries, created and reversed events and one lot, got %+v", d)
+	}
+	if last := d.History[len(d.History)-1]; last.Type != AuditRewardReverse || last.Reason != ReversalDuplicate || last.Actor == "" {
+		t.Fatalf("expected the reversal last in history with its reason and actor, got %+v", last)
+	}
+	if !d.Holdings.ReversedQuantity.Equal(decimal.NewFromInt(1)) || !d.Holdings.NetQuantity.IsZero() || !d.Holdings.Lots[0].RemainingQuantity.IsZero() {
+		t.Fatalf("reversal should undo the reward's holdings, got %+v", d.Holdings)
+	}
+	if _, err := r.GetRewardDetail(ctx, "not-a-uuid"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for a malformed id, got %v", err)
+	}
+}
@@ -0,0 +1,356 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrStockExists  = errors.New("stock already exists")
+	ErrISINInUse    = errors.New("isin belongs to another stock")
+	ErrInvalidStock = errors.New("invalid stock")
+	// ErrFractionalHoldings means lot_precision cannot be lowered because
+	// users hold quantities finer than the new value, which they could then
+	// never sell, transfer or withdraw.
+	ErrFractionalHoldings = errors.New("holdings have more decimal places than the new lot precision")
+)
+
+// Exchanges stocks may be listed on.
+var Exchanges = []string{"NSE", "BSE"}
+
+// MaxLotPrecision is the most decimal places a quantity can have
+// (NUMERIC(18,6)).
+const MaxLotPrecision = 6
+
+var symbolPattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9&-]{0,19}$`)
+
+// Stock is an entry in the stock master. LotPrecision is the number of
+// decimal places a quantity of it may have; 0 means whole shares only.
+// Inactive (delisted) stocks keep their history but cannot be granted.
+type Stock struct {
+	Symbol       string    `db:"symbol" json:"symbol"`
+	Name         string    `db:"name" json:"name"`
+	ISIN         *string   `db:"isin" json:"isin"`
+	Exchange     string    `db:"exchange" json:"exchange"`
+	Sector       *string   `db:"sector" json:"sector"`
+	LotPrecision int32     `db:"lot_precision" json:"lot_precision"`
+	Active       bool      `db:"active" json:"active"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at" json:"updated_at"`
+}
+
+const stockColumns = `symbol, COALESCE(name, symbol) AS name, isin, exchange, sector, lot_precision, active, created_at, updated_at`
+
+// ValidISIN checks the format and check digit of an ISIN.
+func ValidISIN(isin string) bool {
+	if len(isin) != 12 {
+		return false
+	}
+	digits := make([]byte, 0, 24)
+	for i := 0; i < 12; i++ {
+		c := isin[i]
+		switch {
+		case c >= '0' && c <= '9' && i >= 2:
+			digits = append(digits, c-'0')
+		case c >= 'A' && c <= 'Z' && i < 11:
+			v := c - 'A' + 10
+			digits = append(digits, v/10, v%10)
+		default:
+			return false
+		}
+	}
+	// Luhn over the expanded digits, check digit included.
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i])
+		if (len(digits)-1-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// Validate checks a stock about to be created.
+func (s Stock) Validate() error {
+	if !symbolPattern.MatchString(s.Symbol) {
+		return fmt.Errorf("%w: symbol must be 1-20 upper-case letters, digits, & or -", ErrInvalidStock)
+	}
+	return s.validateFields()
+}
+
+// validateFields checks everything but the symbol, which cannot change once
+// created: stocks added before symbols were validated must still be
+// editable and delistable.
+func (s Stock) validateFields() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidStock)
+	}
+	if s.ISIN != nil && !ValidISIN(*s.ISIN) {
+		return fmt.Errorf("%w: isin %q is not a valid ISIN", ErrInvalidStock, *s.ISIN)
+	}
+	known := false
+	for _, e := range Exchanges {
+		known = known || e == s.Exchange
+	}
+	if !known {
+		return fmt.Errorf("%w: exchange must be one of %s", ErrInvalidStock, strings.Join(Exchanges, ", "))
+	}
+	if s.LotPrecision < 0 || s.LotPrecision > MaxLotPrecision {
+		return fmt.Errorf("%w: lot_precision must be between 0 and %d", ErrInvalidStock, MaxLotPrecision)
+	}
+	return nil
+}
+
+// StockSpec describes a stock to create. Exchange defaults to NSE,
+// LotPrecision to MaxLotPrecision and Active to true.
+type StockSpec struct {
+	Symbol       string `json:"symbol"`
+	Name         string `json:"name"`
+	ISIN         string `json:"isin"`
+	Exchange     string `json:"exchange"`
+	Sector       string `json:"sector"`
+	LotPrecision *int32 `json:"lot_precision"`
+	Active       *bool  `json:"active"`
+}
+
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func (sp StockSpec) Stock() Stock {
+	s := Stock{
+		Symbol:       strings.ToUpper(strings.TrimSpace(sp.Symbol)),
+		Name:         strings.TrimSpace(sp.Name),
+		ISIN:         nullString(strings.ToUpper(strings.TrimSpace(sp.ISIN))),
+		Exchange:     strings.ToUpper(strings.TrimSpace(sp.Exchange)),
+		Sector:       nullString(strings.TrimSpace(sp.Sector)),
+		LotPrecision: MaxLotPrecision,
+		Active:       true,
+	}
+	if s.Exchange == "" {
+		s.Exchange = "NSE"
+	}
+	if sp.LotPrecision != nil {
+		s.LotPrecision = *sp.LotPrecision
+	}
+	if sp.Active != nil {
+		s.Active = *sp.Active
+	}
+	return s
+}
+
+// StockUpdate changes the fields that are set. An empty ISIN or Sector
+// clears it.
+type StockUpdate struct {
+	Name         *string `json:"name"`
+	ISIN         *string `json:"isin"`
+	Exchange     *string `json:"exchange"`
+	Sector       *string `json:"sector"`
+	LotPrecision *int32  `json:"lot_precision"`
+	Active       *bool   `json:"active"`
+}
+
+func (u StockUpdate) apply(s Stock) Stock {
+	if u.Name != nil {
+		s.Name = strings.TrimSpace(*u.Name)
+	}
+	if u.ISIN != nil {
+		s.ISIN = nullString(strings.ToUpper(strings.TrimSpace(*u.ISIN)))
+	}
+	if u.Exchange != nil {
+		s.Exchange = strings.ToUpper(strings.TrimSpace(*u.Exchange))
+	}
+	if u.Sector != nil {
+		s.Sector = nullString(strings.TrimSpace(*u.Sector))
+	}
+	if u.LotPrecision != nil {
+		s.LotPrecision = *u.LotPrecision
+	}
+	if u.Active != nil {
+		s.Active = *u.Active
+	}
+	return s
+}
+
+// LoadStocks reads stock seeds from a JSON array of StockSpec.
+func LoadStocks(path string) ([]Stock, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var specs []StockSpec
+	if err := json.Unmarshal(b, &specs); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	res := make([]Stock, 0, len(specs))
+	for _, sp := range specs {
+		s := sp.Stock()
+		if err := s.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", path, s.Symbol, err)
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+func stockSnapshot(ctx context.Context, tx *sqlx.Tx, symbol string) (json.RawMessage, error) {
+	return snapshot(ctx, tx, `SELECT row_to_json(t) FROM stocks t WHERE symbol = $1`, symbol)
+}
+
+func isinConflict(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505" && pqErr.Constraint == "stocks_isin_key"
+}
+
+func (r *Repo) ListStocks(ctx context.Context, includeInactive bool) ([]Stock, error) {
+	res := []Stock{}
+	err := r.db.SelectContext(ctx, &res, `SELECT `+stockColumns+` FROM stocks WHERE $1 OR active ORDER BY symbol`, includeInactive)
+	return res, err
+}
+
+// GetStock returns sql.ErrNoRows for unknown symbols.
+func (r *Repo) GetStock(ctx context.Context, symbol string) (Stock, error) {
+	var s Stock
+	err := r.db.GetContext(ctx, &s, `SELECT `+stockColumns+` FROM stocks WHERE symbol = $1`, symbol)
+	return s, err
+}
+
+// CreateStock adds s to the stock master. It returns ErrStockExists if the
+// symbol is taken and ErrISINInUse if another stock has its ISIN.
+func (r *Repo) CreateStock(ctx context.Context, s Stock) (Stock, error) {
+	if err := s.Validate(); err != nil {
+		return Stock{}, err
+	}
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Stock{}, err
+	}
+	defer tx.Rollback()
+
+	var created Stock
+	err = tx.GetContext(ctx, &created, `
+		INSERT INTO stocks (symbol, name, isin, exchange, sector, lot_precision, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (symbol) DO NOTHING
+		RETURNING `+stockColumns,
+		s.Symbol, s.Name, s.ISIN, s.Exchange, s.Sector, s.LotPrecision, s.Active)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return Stock{}, ErrStockExists
+	case isinConflict(err):
+		return Stock{}, ErrISINInUse
+	case err != nil:
+		return Stock{}, err
+	}
+	after, err := stockSnapshot(ctx, tx, s.Symbol)
+	if err != nil {
+		return Stock{}, err
+	}
+	if err := recordAudit(ctx, tx, AuditStockCreate, "stock", s.Symbol, nil, after, ""); err != nil {
+		return Stock{}, err
+	}
+	return created, tx.Commit()
+}
+
+// UpdateStock applies u to a stock. It returns sql.ErrNoRows for unknown
+// symbols.
+func (r *Repo) UpdateStock(ctx context.Context, symbol string, u StockUpdate) (Stock, error) {
+	action := AuditStockUpdate
+	if u.Active != nil && !*u.Active {
+		action = AuditStockDelist
+	}
+	return r.updateStock(ctx, symbol, u, action)
+}
+
+// DelistStock marks a stock inactive: it keeps its prices, holdings and
+// rewards but can no longer be granted or priced.
+func (r *Repo) DelistStock(ctx context.Context, symbol string) (Stock, error) {
+	inactive := false
+	return r.updateStock(ctx, symbol, StockUpdate{Active: &inactive}, AuditStockDelist)
+}
+
+func (r *Repo) updateStock(ctx context.Context, symbol string, u StockUpdate, action string) (Stock, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Stock{}, err
+	}
+	defer tx.Rollback()
+
+	var cur Stock
+	if err := tx.GetContext(ctx, &cur, `SELECT `+stockColumns+` FROM stocks WHERE symbol = $1 FOR UPDATE`, symbol); err != nil {
+		return Stock{}, err
+	}
+	next := u.apply(cur)
+	if err := next.validateFields(); err != nil {
+		return Stock{}, err
+	}
+	if next.LotPrecision < cur.LotPrecision {
+		var finer bool
+		if err := tx.GetContext(ctx, &finer, `
+			SELECT EXISTS (SELECT 1 FROM holdings WHERE symbol = $1
+				AND (quantity <> trunc(quantity, $2) OR reserved_quantity <> trunc(reserved_quantity, $2)))`, symbol, next.LotPrecision); err != nil {
+			return Stock{}, err
+		}
+		if finer {
+			return Stock{}, ErrFractionalHoldings
+		}
+	}
+	before, err := stockSnapshot(ctx, tx, symbol)
+	if err != nil {
+		return Stock{}, err
+	}
+	var updated Stock
+	err = tx.GetContext(ctx, &updated, `
+		UPDATE stocks SET name = $2, isin = $3, exchange = $4, sector = $5, lot_precision = $6, active = $7, updated_at = now()
+		WHERE symbol = $1
+		RETURNING `+stockColumns,
+		symbol, next.Name, next.ISIN, next.Exchange, next.Sector, next.LotPrecision, next.Active)
+	if isinConflict(err) {
+		return Stock{}, ErrISINInUse
+	}
+	if err != nil {
+		return Stock{}, err
+	}
+	after, err := stockSnapshot(ctx, tx, symbol)
+	if err != nil {
+		return Stock{}, err
+	}
+	if err := recordAudit(ctx, tx, action, "stock", symbol, before, after, ""); err != nil {
+		return Stock{}, err
+	}
+	return updated, tx.Commit()
+}
+
+// SeedStocks creates the stocks that do not exist yet and leaves existing
+// ones as they are. It returns how many it created.
+func (r *Repo) SeedStocks(ctx context.Context, stocks []Stock) (int, error) {
+	created := 0
+	for _, s := range stocks {
+		_, err := r.CreateStock(ctx, s)
+		if errors.Is(err, ErrStockExists) {
+			continue
+		}
+		if err != nil {
+			return created, fmt.Errorf("seed %s: %w", s.Symbol, err)
+		}
+		created++
+	}
+	return created, nil
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestValidISIN(t *testing.T) {
+	for _, isin := range []string{"INE002A01018", "INE467B01029", "INE009A01021", "US0378331005"} {
+		if !ValidISIN(isin) {
+			t.Errorf("%s should be valid", isin)
+		}
+	}
+	for _, isin := range []string{"", "INE002A0101", "INE002A01019", "INE467B01092", "1NE002A01018", "ine002a01018", "INE002A0101X"} {
+		if ValidISIN(isin) {
+			t.Errorf("%s should be invalid", isin)
+		}
+	}
+}
+
+func TestStockSpecDefaultsAndValidation(t *testing.T) {
+	s := StockSpec{Symbol: " hdfcbank ", Name: "HDFC Bank", ISIN: "ine040a01034"}.Stock()
+	if s.Symbol != "HDFCBANK" || s.Exchange != "NSE" || s.LotPrecision != MaxLotPrecision || !s.Active || s.ISIN == nil || *s.ISIN != "INE040A01034" || s.Sector != nil {
+		t.Fatalf("unexpected defaults: %+v", s)
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("valid stock rejected: %v", err)
+	}
+
+	zero := int32(0)
+	whole := StockSpec{Symbol: "M&M", Name: "Mahindra & Mahindra", Exchange: "bse", LotPrecision: &zero}.Stock()
+	if whole.Exchange != "BSE" || whole.LotPrecision != 0 || whole.Validate() != nil {
+		t.Fatalf("whole-share BSE stock should be valid: %+v (%v)", whole, whole.Validate())
+	}
+
+	seven := int32(7)
+	bad := []StockSpec{
+		{Symbol: "", Name: "No Symbol"},
+		{Symbol: "TCS!", Name: "Bad Symbol"},
+		{Symbol: "TCS"},
+		{Symbol: "TCS", Name: "TCS", ISIN: "INE467B01092"},
+		{Symbol: "TCS", Name: "TCS", Exchange: "NYSE"},
+		{Symbol: "TCS", Name: "TCS", LotPrecision: &seven},
+	}
+	for _, sp := range bad {
+		if err := sp.Stock().Validate(); !errors.Is(err, ErrInvalidStock) {
+			t.Errorf("%+v should be invalid, got %v", sp, err)
+		}
+	}
+}
+
+func TestLoadStocks(t *testing.T) {
+	stocks, err := LoadStocks("../../data/stocks.json")
+	if err != nil {
+		t.Fatalf("load stocks: %v", err)
+	}
+	if len(stocks) < 3 {
+		t.Fatalf("expected the seed stocks, got %+v", stocks)
+	}
+	for _, s := range stocks {
+		if !s.Active || s.ISIN == nil {
+			t.Errorf("seed %s should be active with an ISIN", s.Symbol)
+		}
+	}
+}
+
+func TestStockMasterLifecycle(t *testing.T) {
+	db := setupDB(t)
+	r := New(db, logrus.New())
+	ctx := WithActor(context.Background(), Actor{Subject: "test-stock-admin", Role: "admin"})
+
+	symbol := "TST" + time.Now().Format("150405")
+	sector := "Testing"
+	s, err := r.CreateStock(ctx, Stock{Symbol: symbol, Name: "Test Stock", Exchange: "NSE", Sector: &sector, LotPrecision: 2, Active: true})
+	if err != nil {
+		t.Fatalf("create stock failed: %v", err)
+	}
+	if s.LotPrecision != 2 || !s.Active || s.Sector == nil || *s.Sector != sector {
+		t.Fatalf("unexpected stock %+v", s)
+	}
+	if _, err := r.CreateStock(ctx, s); !errors.Is(err, ErrStockExists) {
+		t.Fatalf("expected ErrStockExists, got %v", err)
+	}
+	if _, err := r.CreateStock(ctx, Stock{Symbol: symbol + "X", Name: "Dup ISIN", Exchange: "NSE", ISIN: strPtr("INE009A01021"), LotPrecision: 6, Active: true}); !errors.Is(err, ErrISINInUse) {
+		t.Fatalf("expected ErrISINInUse, got %v", err)
+	}
+
+	name := "Renamed Test Stock"
+	if s, err = r.UpdateStock(ctx, symbol, StockUpdate{Name: &name}); err != nil || s.Name != name {
+		t.Fatalf("update stock failed: %+v (err %v)", s, err)
+	}
+	bad := int32(9)
+	if _, err := r.UpdateStock(ctx, symbol, StockUpdate{LotPrecision: &bad}); !errors.Is(err, ErrInvalidStock) {
+		t.Fatalf("expected ErrInvalidStock, got %v", err)
+	}
+
+	holder := "test-stock-holder"
+	if _, err := db.Exec("INSERT INTO users (id, name) VALUES ($1, $1) ON CONFLICT (id) DO NOTHING", holder); err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO holdings (user_id, symbol, quantity) VALUES ($1, $2, 0.5)", holder, symbol); err != nil {
+		t.Fatalf("seed holdings failed: %v", err)
+	}
+	whole := int32(0)
+	if _, err := r.UpdateStock(ctx, symbol, StockUpdate{LotPrecision: &whole}); !errors.Is(err, ErrFractionalHoldings) {
+		t.Fatalf("expected ErrFractionalHoldings, got %v", err)
+	}
+	one := int32(1)
+	if s, err = r.UpdateStock(ctx, symbol, StockUpdate{LotPrecision: &one}); err != nil || s.LotPrecision != 1 {
+		t.Fatalf("lowering to a precision the holdings fit should work: %+v (err %v)", s, err)
+	}
+
+	if s, err = r.DelistStock(ctx, symbol); err != nil || s.Active {
+		t.Fatalf("delist stock failed: %+v (err %v)", s, err)
+	}
+	active, err := r.ListStocks(ctx, false)
+	if err != nil {
+		t.Fatalf("list stocks failed: %v", err)
+	}
+	for _, st := range active {
+		if st.Symbol == symbol {
+			t.Fatal("delisted stock should not be listed as active")
+		}
+	}
+	symbols, err := r.GetAllSymbols(ctx)
+	if err != nil {
+		t.Fatalf("get symbols failed: %v", err)
+	}
+	for _, sym := range symbols {
+		if sym == symbol {
+			t.Fatal("delisted stock should not be priced")
+		}
+	}
+
+	// Stocks that predate symbol validation can still be edited and delisted.
+	legacy := "tst." + time.Now().Format("150405")
+	if _, err := db.Exec("INSERT INTO stocks (symbol, name) VALUES ($1, $1) ON CONFLICT (symbol) DO NOTHING", legacy); err != nil {
+		t.Fatalf("seed legacy stock failed: %v", err)
+	}
+	if _, err := r.UpdateStock(ctx, legacy, StockUpdate{Name: &name}); err != nil {
+		t.Fatalf("update legacy stock failed: %v", err)
+	}
+	if st, err := r.DelistStock(ctx, legacy); err != nil || st.Active {
+		t.Fatalf("delist legacy stock failed: %+v (err %v)", st, err)
+	}
+
+	events, _, err := r.ListAuditEvents(ctx, AuditFilter{EntityType: "stock", EntityID: symbol, Limit: 10})
+	if err != nil || len(events) != 4 || events[0].Action != AuditStockDelist || events[3].Action != AuditStockCreate {
+		t.Fatalf("expected create, two updates and delist audit events, got %+v (err %v)", events, err)
+	}
+}
+
+func strPtr(s string) *string { return &s }
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"stocky/internal/calendar"
+)
+
+// WithTimezone sets the zone whose midnight ends a day for users who have
+// not chosen their own; the default is calendar.DefaultTimezone.
+func WithTimezone(loc *time.Location) Option {
+	return func(r *Repo) { r.location = loc }
+}
+
+// UserLocation returns the zone the user's days follow. An unknown stored
+// zone falls back to the default rather than failing the request.
+func (r *Repo) UserLocation(ctx context.Context, userID string) (*time.Location, error) {
+	var name sql.NullString
+	err := r.db.GetContext(ctx, &name, `SELECT timezone FROM users WHERE id = $1`, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if !name.Valid || name.String == "" {
+		return r.location, nil
+	}
+	loc, err := calendar.LoadLocation(name.String)
+	if err != nil {
+		r.log.Warnf("user %s has unknown timezone %q: %v", userID, name.String, err)
+		return r.location, nil
+	}
+	return loc, nil
+}
+
+// SetUserTimezone stores the user's zone; empty restores the default. It
+// returns sql.ErrNoRows for unknown users.
+func (r *Repo) SetUserTimezone(ctx context.Context, userID, name string) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE users SET timezone = NULLIF($2, '') WHERE id = $1`, userID, name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Location is the default zone, used for users without their own and for
+// operator-facing date filters.
+func (r *Repo) Location() *time.Location {
+	return r.location
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"stocky/internal/calendar"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+func TestTodayRewardsFollowUserTimezone(t *testing.T) {
+	db := setupDB(t)
+	r := New(db, logrus.New())
+	ctx := context.Background()
+
+	user, symbol := "test-tz-user", "TCS"
+	if _, err := db.Exec("INSERT INTO users (id, name) VALUES ($1, $1) ON CONFLICT (id) DO NOTHING", user); err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	_, _ = db.Exec("DELETE FROM ledger_entries WHERE reward_id IN (SELECT id FROM rewards WHERE user_id = $1)", user)
+	_, _ = db.Exec("DELETE FROM holding_lots WHERE user_id = $1", user)
+	_, _ = db.Exec("DELETE FROM rewards WHERE user_id = $1", user)
+	if err := r.SetUserTimezone(ctx, user, ""); err != nil {
+		t.Fatalf("reset timezone failed: %v", err)
+	}
+
+	// One reward just after midnight IST, which is the previous day in UTC
+	// until 05:30 IST, and one just before it.
+	istMidnight := calendar.DayStart(time.Now(), calendar.IST)
+	for i, ts := range []time.Time{istMidnight.Add(time.Minute), istMidnight.Add(-time.Minute)} {
+		if _, _, err := r.CreateReward(ctx, RewardInput{UserID: user, Symbol: symbol, Quantity: decimal.NewFromInt(int64(i + 1)), Timestamp: ts, IdempotencyKey: fmt.Sprintf("test-tz-%d", i), Source: "test", Price: decimal.NewFromInt(100)}); err != nil {
+			t.Fatalf("create reward failed: %v", err)
+		}
+	}
+
+	rows, err := r.GetTodayRewards(ctx, user)
+	if err != nil {
+		t.Fatalf("get today rewards failed: %v", err)
+	}
+	if len(rows) != 1 || !rows[0].Quantity.Equal(decimal.NewFromInt(1)) {
+		t.Fatalf("expected only the reward after IST midnight, got %+v", rows)
+	}
+
+	if err := r.SetUserTimezone(ctx, user, "UTC"); err != nil {
+		t.Fatalf("set timezone failed: %v", err)
+	}
+	rows, err = r.GetTodayRewards(ctx, user)
+	if err != nil {
+		t.Fatalf("get today rewards failed: %v", err)
+	}
+	utcMidnight := calendar.DayStart(time.Now(), time.UTC)
+	for _, rw := range rows {
+		if rw.Timestamp.Before(utcMidnight) {
+			t.Fatalf("UTC user got a reward from before UTC midnight: %+v", rw)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"stocky/internal/events"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+var ErrInsufficientHoldings = errors.New("insufficient holdings")
+
+const (
+	TransferGift  = "gift"
+	TransferMerge = "merge"
+)
+
+type Transfer struct {
+	ID             string          `db:"id" json:"id"`
+	FromUserID     string          `db:"from_user_id" json:"from_user_id"`
+	ToUserID       string          `db:"to_user_id" json:"to_user_id"`
+	Symbol         string          `db:"symbol" json:"symbol"`
+	Quantity       decimal.Decimal `db:"quantity" json:"quantity"`
+	PriceINR       decimal.Decimal `db:"price_inr" json:"price_inr"`
+	Kind           string          `db:"kind" json:"kind"`
+	Note           *string         `db:"note" json:"note,omitempty"`
+	InitiatedBy    string          `db:"initiated_by" json:"initiated_by"`
+	IdempotencyKey *string         `db:"idempotency_key" json:"idempotency_key,omitempty"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+}
+
+const transferColumns = `id, from_user_id, to_user_id, symbol, quantity, price_inr, kind, note, initiated_by, idempotency_key, created_at`
+
+// postRefEntry writes a ledger entry that belongs to something other than a
+// reward, such as a transfer.
+func postRefEntry(ctx context.Context, tx *sqlx.Tx, refType, refID, debit, credit string, amount decimal.Decimal, symbol *string, quantity *decimal.Decimal, desc string) error {
+	var qty *string
+	if quantity != nil {
+		q := quantity.StringFixed(6)
+		qty = &q
+	}
+	_, err := tx.ExecContext(ctx, `INSERT INTO ledger_entries (id, entry_time, account_debit, account_credit, amount_inr, stock_symbol, stock_quantity, description, ref_type, ref_id) VALUES (gen_random_uuid(), now(), $1, $2, $3::numeric, $4, $5::numeric, $6, $7, $8)`,
+		debit, credit, amount.StringFixed(4), symbol, qty, desc, refType, refID)
+	return err
+}
+
+// lockHoldings locks the holdings rows of users for symbol in a fixed order
+// so concurrent transfers between the same users cannot deadlock, and
+// returns each user's available quantity, excluding shares reserved for
+// withdrawals.
+func lockHoldings(ctx context.Context, tx *sqlx.Tx, symbol string, userIDs ...string) (map[string]decimal.Decimal, error) {
+	rows, err := tx.QueryxContext(ctx, `SELECT user_id, quantity - reserved_quantity FROM holdings WHERE symbol = $1 AND user_id = ANY($2) ORDER BY user_id FOR UPDATE`, symbol, pq.StringArray(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := map[string]decimal.Decimal{}
+	for rows.Next() {
+		var id string
+		var q decimal.Decimal
+		if err := rows.Scan(&id, &q); err != nil {
+			return nil, err
+		}
+		res[id] = q
+	}
+	return res, rows.Err()
+}
+
+// CreateTransfer moves t.Quantity of t.Symbol from one user's holdings to
+// another's. Idempotency keys are scoped to the sender: replaying one returns
+// the sender's original transfer with created=false.
+func (r *Repo) CreateTransfer(ctx context.Context, t Transfer) (Transfer, bool, error) {
+	var res Transfer
+	if t.IdempotencyKey != nil {
+		err := r.db.GetContext(ctx, &res, `SELECT `+transferColumns+` FROM transfers WHERE from_user_id = $1 AND idempotency_key = $2`, t.FromUserID, *t.IdempotencyKey)
+		if err == nil {
+			return res, false, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return res, false, err
+		}
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return res, false, err
+	}
+	defer tx.Rollback()
+
+	held, err := lockHoldings(ctx, tx, t.Symbol, t.FromUserID, t.ToUserID)
+	if err != nil {
+		return res, false, err
+	}
+	if held[t.FromUserID].LessThan(t.Quantity) {
+		return res, false, ErrInsufficientHoldings
+	}
+
+	if err := tx.GetContext(ctx, &res, `INSERT INTO transfers (from_user_id, to_user_id, symbol, quantity, price_inr, kind, note, initiated_by, idempotency_key) VALUES ($1, $2, $3, $4::numeric, $5::numeric, $6, $7, $8, $9) RETURNING `+transferColumns,
+		t.FromUserID, t.ToUserID, t.Symbol, t.Quantity.StringFixed(6), t.PriceINR.StringFixed(4), t.Kind, t.Note, t.InitiatedBy, t.IdempotencyKey); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" && t.IdempotencyKey != nil {
+			tx.Rollback()
+			if err := r.db.GetContext(ctx, &res, `SELECT `+transferColumns+` FROM transfers WHERE from_user_id = $1 AND idempotency_key = $2`, t.FromUserID, *t.IdempotencyKey); err == nil {
+				return res, false, nil
+			}
+		}
+		return res, false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE holdings SET quantity = quantity - $3::numeric, last_updated = now() WHERE user_id = $1 AND symbol = $2`, t.FromUserID, t.Symbol, t.Quantity.String()); err != nil {
+		return res, false, err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO holdings (user_id, symbol, quantity, last_updated) VALUES ($1, $2, $3::numeric, now()) ON CONFLICT (user_id, symbol) DO UPDATE SET quantity = holdings.quantity + $3::numeric, last_updated = now()`, t.ToUserID, t.Symbol, t.Quantity.String()); err != nil {
+		return res, false, err
+	}
+
+	// The recipient takes over the sender's cost and acquisition dates.
+	uses, err := consumeLots(ctx, tx, t.FromUserID, t.Symbol, t.Quantity, nil)
+	if err != nil {
+		return res, false, err
+	}
+	for _, u := range uses {
+		if err := addLot(ctx, tx, t.ToUserID, t.Symbol, u.Quantity, u.Lot.CostPerShareINR, u.Lot.AcquiredAt, LotTransfer, &res.ID); err != nil {
+			return res, false, err
+		}
+	}
+
+	if err := postRefEntry(ctx, tx, "transfer", res.ID, "user_holdings:"+t.FromUserID, "user_holdings:"+t.ToUserID, t.Quantity.Mul(t.PriceINR), &t.Symbol, &t.Quantity, "holdings transfer"); err != nil {
+		return res, false, err
+	}
+	if err := enqueueOutbox(ctx, tx, events.TransferDone, map[string]interface{}{
+		"transfer_id":  res.ID,
+		"from_user_id": res.FromUserID,
+		"to_user_id":   res.ToUserID,
+		"symbol":       res.Symbol,
+		"quantity":     res.Quantity.StringFixed(6),
+		"kind":         res.Kind,
+	}); err != nil {
+		return res, false, err
+	}
+	return res, true, tx.Commit()
+}
+
+// ListTransfers returns the transfers a user sent or received, newest first.
+func (r *Repo) ListTransfers(ctx context.Context, userID string, limit int) ([]Transfer, error) {
+	res := []Transfer{}
+	err := r.db.SelectContext(ctx, &res, `SELECT `+transferColumns+` FROM transfers WHERE from_user_id = $1 OR to_user_id = $1 ORDER BY created_at DESC LIMIT $2`, userID, limit)
+	return res, err
+}
+
+func (r *Repo) UserExists(ctx context.Context, userID string) (bool, error) {
+	var ok bool
+	err := r.db.GetContext(ctx, &ok, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, userID)
+	return ok, err
+}
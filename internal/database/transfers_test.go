@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+func TestCreateTransfer(t *testing.T) {
+	db := setupDB(t)
+	r := New(db, logrus.New())
+	ctx := context.Background()
+
+	from, to, symbol := "test-transfer-from", "test-transfer-to", "TCS"
+	for _, id := range []string{from, to} {
+		if _, err := db.Exec("INSERT INTO users (id, name) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING", id, id); err != nil {
+			t.Fatalf("create user failed: %v", err)
+		}
+	}
+	_, _ = db.Exec("DELETE FROM ledger_entries WHERE ref_type = 'transfer' AND ref_id IN (SELECT id FROM transfers WHERE from_user_id = $1)", from)
+	_, _ = db.Exec("DELETE FROM transfers WHERE from_user_id = $1", from)
+	_, _ = db.Exec("DELETE FROM holdings WHERE user_id IN ($1, $2)", from, to)
+	_, _ = db.Exec("DELETE FROM holding_lots WHERE user_id IN ($1, $2)", from, to)
+	if _, err := db.Exec("INSERT INTO holdings (user_id, symbol, quantity) VALUES ($1, $2, 5)", from, symbol); err != nil {
+		t.Fatalf("seed holdings failed: %v", err)
+	}
+	if err := seedLot(db, from, symbol, 5); err != nil {
+		t.Fatalf("seed lot failed: %v", err)
+	}
+
+	key := "test-transfer-key"
+	tr := Transfer{FromUserID: from, ToUserID: to, Symbol: symbol, Quantity: decimal.NewFromInt(2), PriceINR: decimal.NewFromInt(3500), Kind: TransferGift, InitiatedBy: from, IdempotencyKey: &key}
+	first, created, err := r.CreateTransfer(ctx, tr)
+	if err != nil || !created {
+		t.Fatalf("transfer failed: created=%v err=%v", created, err)
+	}
+	again, created, err := r.CreateTransfer(ctx, tr)
+	if err != nil || created || again.ID != first.ID {
+		t.Fatalf("replay should return the original transfer, got %+v created=%v err=%v", again, created, err)
+	}
+
+	// Another sender may use the same key without getting this transfer.
+	_, _ = db.Exec("DELETE FROM ledger_entries WHERE ref_type = 'transfer' AND ref_id IN (SELECT id FROM transfers WHERE from_user_id = $1)", to)
+	_, _ = db.Exec("DELETE FROM transfers WHERE from_user_id = $1", to)
+	back := Transfer{FromUserID: to, ToUserID: from, Symbol: symbol, Quantity: decimal.NewFromInt(1), PriceINR: decimal.NewFromInt(3500), Kind: TransferGift, InitiatedBy: to, IdempotencyKey: &key}
+	other, created, err := r.CreateTransfer(ctx, back)
+	if err != nil || !created || other.ID == first.ID {
+		t.Fatalf("same key from another sender should create a transfer, got %+v created=%v err=%v", other, created, err)
+	}
+
+	tr.IdempotencyKey = nil
+	tr.Quantity = decimal.NewFromInt(5)
+	if _, _, err := r.CreateTransfer(ctx, tr); !errors.Is(err, ErrInsufficientHoldings) {
+		t.Fatalf("expected ErrInsufficientHoldings, got %v", err)
+	}
+
+	for user, want := range map[string]int64{from: 4, to: 1} {
+		h, err := r.GetHoldings(ctx, user)
+		if err != nil {
+			t.Fatalf("get holdings failed: %v", err)
+		}
+		if len(h) != 1 || !h[0].Quantity.Equal(decimal.NewFromInt(want)) {
+			t.Fatalf("%s: expected %d %s, got %v", user, want, symbol, h)
+		}
+	}
+}
@@ -2,19 +2,47 @@ package database
 
 import "github.com/shopspring/decimal"
 
+// DailyValuation is a user's end-of-day value. Symbols breaks it down when
+// requested; Intraday marks today's value at the latest prices.
 type DailyValuation struct {
-	TotalINR decimal.Decimal `db:"total_inr" json:"total_inr"`
-	Date     string          `db:"date" json:"date"`
+	TotalINR decimal.Decimal            `db:"total_inr" json:"total_inr"`
+	Date     string                     `db:"date" json:"date"`
+	Symbols  map[string]decimal.Decimal `db:"-" json:"symbols,omitempty"`
+	Intraday bool                       `db:"-" json:"intraday,omitempty"`
 }
 
+// PortfolioItem values the vested quantity held; unvested shares are shown
+// separately and not counted in CurrentValue. Cost basis and unrealised gain
+// cover the vested quantity only.
 type PortfolioItem struct {
-	Symbol       string          `json:"symbol"`
-	Quantity     decimal.Decimal `json:"quantity"`
-	CurrentPrice decimal.Decimal `json:"current_price"`
-	CurrentValue decimal.Decimal `json:"current_value"`
+	Symbol           string          `json:"symbol"`
+	Quantity         decimal.Decimal `json:"quantity"`
+	VestedQuantity   decimal.Decimal `json:"vested_quantity"`
+	UnvestedQuantity decimal.Decimal `json:"unvested_quantity"`
+	ReservedQuantity decimal.Decimal `json:"reserved_quantity"`
+	CurrentPrice     decimal.Decimal `json:"current_price"`
+	CurrentValue     decimal.Decimal `json:"current_value"`
+	UnvestedValue    decimal.Decimal `json:"unvested_value"`
+	InvestedINR      decimal.Decimal `json:"invested_inr"`
+	AverageCostINR   decimal.Decimal `json:"average_cost_inr"`
+	UnrealisedINR    decimal.Decimal `json:"unrealised_inr"`
+	UnrealisedPct    decimal.Decimal `json:"unrealised_pct"`
+	Lots             []Lot           `json:"lots"`
 }
 
+// GainPct is gain as a percentage of invested, to two decimal places, or
+// zero when nothing was invested.
+func GainPct(gain, invested decimal.Decimal) decimal.Decimal {
+	if !invested.IsPositive() {
+		return decimal.Zero
+	}
+	return gain.Mul(decimal.NewFromInt(100)).DivRound(invested, 2)
+}
+
+// Holding is a user's vested quantity of a symbol. Reserved shares are held
+// back for pending withdrawals.
 type Holding struct {
 	Symbol   string          `db:"symbol" json:"symbol"`
 	Quantity decimal.Decimal `db:"quantity" json:"quantity"`
+	Reserved decimal.Decimal `db:"reserved_quantity" json:"reserved_quantity"`
 }
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// Valuation granularities; weekly and monthly keep each period's last value.
+const (
+	GranularityDaily   = "daily"
+	GranularityWeekly  = "weekly"
+	GranularityMonthly = "monthly"
+)
+
+// ValuationQuery narrows GetDailyValuations. Zero From/To leave the range
+// open and an empty Granularity means daily. From and To are dates; only
+// their year, month and day are used. Days end at midnight in Location,
+// which defaults to the user's timezone.
+type ValuationQuery struct {
+	From         time.Time
+	To           time.Time
+	Granularity  string
+	IncludeToday bool
+	BySymbol     bool
+	Location     *time.Location
+}
+
+// localDate is midnight in loc on t's date.
+func localDate(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+func nullDate(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	s := t.Format("2006-01-02")
+	return &s
+}
+
+// Resample keeps the last of vals in each week (ISO weeks, Monday to Sunday)
+// or month. vals must be in date order.
+func Resample(vals []DailyValuation, granularity string) []DailyValuation {
+	if granularity == "" || granularity == GranularityDaily {
+		return vals
+	}
+	res := []DailyValuation{}
+	lastKey := ""
+	for _, v := range vals {
+		d, err := time.Parse("2006-01-02", v.Date)
+		if err != nil {
+			continue
+		}
+		key := d.Format("2006-01")
+		if granularity == GranularityWeekly {
+			y, w := d.ISOWeek()
+			key = fmt.Sprintf("%d-W%02d", y, w)
+		}
+		if key == lastKey {
+			res[len(res)-1] = v
+			continue
+		}
+		res = append(res, v)
+		lastKey = key
+	}
+	return res
+}
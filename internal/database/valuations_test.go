@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"stocky/internal/performance"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+func TestResample(t *testing.T) {
+	vals := []DailyValuation{}
+	// 2025-01-27 is a Monday; the range crosses a week and a month boundary.
+	for i, date := range []string{"2025-01-27", "2025-01-31", "2025-02-02", "2025-02-03", "2025-02-04"} {
+		vals = append(vals, DailyValuation{Date: date, TotalINR: decimal.NewFromInt(int64(i))})
+	}
+
+	dates := func(vs []DailyValuation) []string {
+		res := []string{}
+		for _, v := range vs {
+			res = append(res, v.Date)
+		}
+		return res
+	}
+	cases := map[string][]string{
+		GranularityDaily:   {"2025-01-27", "2025-01-31", "2025-02-02", "2025-02-03", "2025-02-04"},
+		"":                 {"2025-01-27", "2025-01-31", "2025-02-02", "2025-02-03", "2025-02-04"},
+		GranularityWeekly:  {"2025-02-02", "2025-02-04"},
+		GranularityMonthly: {"2025-01-31", "2025-02-04"},
+	}
+	for g, want := range cases {
+		got := dates(Resample(vals, g))
+		if len(got) != len(want) {
+			t.Errorf("%q: got %v, want %v", g, got, want)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%q: got %v, want %v", g, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestSnapshotValuationsFeedPerformance(t *testing.T) {
+	db := setupDB(t)
+	r := New(db, logrus.New())
+	ctx := context.Background()
+
+	user := "test-snapshot-valuations-user"
+	if _, err := db.Exec("INSERT INTO users (id, name) VALUES ($1, $1) ON CONFLICT (id) DO NOTHING", user); err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	_, _ = db.Exec("DELETE FROM daily_valuations WHERE user_id = $1", user)
+	for i, date := range []string{"2025-01-01", "2025-01-02", "2025-01-03"} {
+		if _, err := db.Exec("INSERT INTO daily_valuations (user_id, date, total_inr) VALUES ($1, $2, $3)", user, date, 1000+100*i); err != nil {
+			t.Fatalf("store snapshot failed: %v", err)
+		}
+	}
+
+	rows, err := r.GetDailyValuations(ctx, user, ValuationQuery{Location: time.UTC})
+	if err != nil {
+		t.Fatalf("get daily valuations failed: %v", err)
+	}
+	monthly, err := r.GetDailyValuations(ctx, user, ValuationQuery{Location: time.UTC, Granularity: GranularityMonthly})
+	if err != nil || len(monthly) != 1 || monthly[0].Date != "2025-01-03" {
+		t.Fatalf("expected the month's last snapshot dated 2025-01-03, got %+v (err %v)", monthly, err)
+	}
+
+	// The /performance handler builds its points exactly like this.
+	points := []performance.Point{}
+	for _, v := range rows {
+		d, err := time.Parse("2006-01-02", v.Date)
+		if err != nil {
+			t.Fatalf("snapshot date %q is not YYYY-MM-DD", v.Date)
+		}
+		points = append(points, performance.Point{Date: d, Value: v.TotalINR})
+	}
+	if len(points) != 3 {
+		t.Fatalf("expected 3 snapshot points, got %+v", rows)
+	}
+	res := performance.Compute(points, nil, time.UTC)
+	if res.From != "2025-01-02" || res.To != "2025-01-03" || !res.EndValue.Equal(decimal.NewFromInt(1200)) {
+		t.Fatalf("unexpected performance from snapshots: %+v", res)
+	}
+}
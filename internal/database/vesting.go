@@ -0,0 +1,258 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"stocky/internal/events"
+	"stocky/internal/vesting"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	VestingActive   = "VESTING"
+	VestingComplete = "VESTED"
+	VestingLapsed   = "LAPSED"
+	VestingReversed = "REVERSED"
+)
+
+// Unvested shares are bought up front and parked in unvested_stock until
+// they vest into stock_inventory or lapse into forfeited_stock.
+const (
+	accountUnvested  = "unvested_stock"
+	accountInventory = "stock_inventory"
+	accountForfeited = "forfeited_stock"
+)
+
+func postStockEntry(ctx context.Context, tx *sqlx.Tx, rewardID, debit, credit, symbol string, quantity, price decimal.Decimal, desc string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO ledger_entries (id, reward_id, entry_time, account_debit, account_credit, amount_inr, stock_symbol, stock_quantity, description) VALUES (gen_random_uuid(), $1, now(), $2, $3, $4::numeric, $5, $6::numeric, $7)`,
+		rewardID, debit, credit, quantity.Mul(price).StringFixed(4), symbol, quantity.StringFixed(6), desc)
+	return err
+}
+
+// startVesting parks a credited reward in unvested_stock and schedules its
+// first tranche.
+func startVesting(ctx context.Context, tx *sqlx.Tx, b bookedReward) error {
+	if err := postStockEntry(ctx, tx, b.ID, accountInventory, accountUnvested, b.Symbol, b.Quantity, b.Price, "unvested grant"); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `INSERT INTO reward_vesting (reward_id, user_id, symbol, next_vest_at) VALUES ($1, $2, $3, $4)`,
+		b.ID, b.UserID, b.Symbol, b.Vesting.NextVest(b.Timestamp, b.Timestamp))
+	return err
+}
+
+// VestingRelease is a change to a grant made by the vesting job or a lapse.
+type VestingRelease struct {
+	RewardID string          `json:"reward_id"`
+	UserID   string          `json:"user_id"`
+	Symbol   string          `json:"symbol"`
+	Quantity decimal.Decimal `json:"quantity"`
+}
+
+type vestingGrant struct {
+	RewardID  string          `db:"reward_id"`
+	UserID    string          `db:"user_id"`
+	Symbol    string          `db:"symbol"`
+	Quantity  decimal.Decimal `db:"quantity"`
+	Vested    decimal.Decimal `db:"vested_quantity"`
+	Price     decimal.Decimal `db:"price_inr"`
+	Timestamp time.Time       `db:"timestamp"`
+	Cliff     int             `db:"vesting_cliff_months"`
+	Months    int             `db:"vesting_months"`
+	Status    string          `db:"status"`
+	LapsedAt  *time.Time      `db:"lapsed_at"`
+}
+
+func (g vestingGrant) schedule() vesting.Schedule {
+	return vesting.Schedule{CliffMonths: g.Cliff, Months: g.Months}
+}
+
+// heldAt is the part of the grant the user held at t.
+func (g vestingGrant) heldAt(t time.Time) decimal.Decimal {
+	if g.LapsedAt != nil && !g.LapsedAt.After(t) {
+		return g.Vested
+	}
+	return g.schedule().VestedAt(g.Quantity, g.Timestamp, t)
+}
+
+const vestingGrantColumns = `rv.reward_id, rv.user_id, rv.symbol, rw.quantity, rv.vested_quantity, rw.price_inr, rw.timestamp,
+	rw.vesting_cliff_months, rw.vesting_months, rv.status, rv.lapsed_at`
+
+// VestDue releases every tranche due by now into holdings. Grants are
+// locked with SKIP LOCKED so concurrent jobs do not double-vest.
+func (r *Repo) VestDue(ctx context.Context, now time.Time, limit int) ([]VestingRelease, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	grants := []vestingGrant{}
+	if err := tx.SelectContext(ctx, &grants, `
+		SELECT `+vestingGrantColumns+`
+		FROM reward_vesting rv
+		JOIN rewards rw ON rw.id = rv.reward_id
+		WHERE rv.status = 'VESTING' AND rv.next_vest_at <= $1
+		ORDER BY rv.next_vest_at
+		LIMIT $2
+		FOR UPDATE OF rv SKIP LOCKED`, now, limit); err != nil {
+		return nil, err
+	}
+
+	res := []VestingRelease{}
+	for _, g := range grants {
+		s := g.schedule()
+		vested := s.VestedAt(g.Quantity, g.Timestamp, now)
+		delta := vested.Sub(g.Vested)
+		next := s.NextVest(g.Timestamp, now)
+		status := VestingActive
+		var nextAt *time.Time
+		if next.IsZero() {
+			status = VestingComplete
+		} else {
+			nextAt = &next
+		}
+		if delta.IsPositive() {
+			if err := postStockEntry(ctx, tx, g.RewardID, accountUnvested, accountInventory, g.Symbol, delta, g.Price, "vested tranche"); err != nil {
+				return nil, err
+			}
+			if _, err := tx.ExecContext(ctx, `INSERT INTO holdings (user_id, symbol, quantity, last_updated) VALUES ($1, $2, $3::numeric, now()) ON CONFLICT (user_id, symbol) DO UPDATE SET quantity = holdings.quantity + $3::numeric, last_updated = now()`, g.UserID, g.Symbol, delta.String()); err != nil {
+				return nil, err
+			}
+			if err := addLot(ctx, tx, g.UserID, g.Symbol, delta, g.Price, now, LotReward, &g.RewardID); err != nil {
+				return nil, err
+			}
+			if err := enqueueOutbox(ctx, tx, events.RewardVested, map[string]interface{}{
+				"reward_id":       g.RewardID,
+				"user_id":         g.UserID,
+				"symbol":          g.Symbol,
+				"quantity":        delta.StringFixed(6),
+				"vested_quantity": vested.StringFixed(6),
+				"total_quantity":  g.Quantity.StringFixed(6),
+			}); err != nil {
+				return nil, err
+			}
+			res = append(res, VestingRelease{RewardID: g.RewardID, UserID: g.UserID, Symbol: g.Symbol, Quantity: delta})
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE reward_vesting SET vested_quantity = $2::numeric, status = $3, next_vest_at = $4, updated_at = now() WHERE reward_id = $1`,
+			g.RewardID, vested.String(), status, nextAt); err != nil {
+			return nil, err
+		}
+	}
+	return res, tx.Commit()
+}
+
+// closeVesting stops vesting a locked grant and moves its unvested remainder
+// out of unvested_stock. It returns the quantity that had already vested, or
+// ok=false if the reward has no vesting schedule.
+func closeVesting(ctx context.Context, tx *sqlx.Tx, rewardID, status, toAccount, desc string) (VestingRelease, decimal.Decimal, bool, error) {
+	var g vestingGrant
+	err := tx.GetContext(ctx, &g, `
+		SELECT `+vestingGrantColumns+`
+		FROM reward_vesting rv
+		JOIN rewards rw ON rw.id = rv.reward_id
+		WHERE rv.reward_id = $1
+		FOR UPDATE OF rv`, rewardID)
+	if err == sql.ErrNoRows {
+		return VestingRelease{}, decimal.Zero, false, nil
+	}
+	if err != nil {
+		return VestingRelease{}, decimal.Zero, false, err
+	}
+	remaining := decimal.Zero
+	if g.Status == VestingActive {
+		remaining = g.Quantity.Sub(g.Vested)
+	}
+	if remaining.IsPositive() {
+		if err := postStockEntry(ctx, tx, rewardID, accountUnvested, toAccount, g.Symbol, remaining, g.Price, desc); err != nil {
+			return VestingRelease{}, decimal.Zero, false, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE reward_vesting SET status = $2, lapsed_quantity = $3::numeric, lapsed_at = now(), next_vest_at = NULL, updated_at = now() WHERE reward_id = $1`,
+		rewardID, status, remaining.String()); err != nil {
+		return VestingRelease{}, decimal.Zero, false, err
+	}
+	return VestingRelease{RewardID: rewardID, UserID: g.UserID, Symbol: g.Symbol, Quantity: remaining}, g.Vested, true, nil
+}
+
+// LapseUnvested forfeits the unvested part of every grant still vesting for
+// a user who has churned. Vested shares stay in the user's holdings. Vesting
+// grants still awaiting approval are rejected, so approving one later cannot
+// start vesting for the churned user; their ids are returned too.
+func (r *Repo) LapseUnvested(ctx context.Context, userID string) ([]VestingRelease, []string, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var ids []string
+	if err := tx.SelectContext(ctx, &ids, `SELECT reward_id FROM reward_vesting WHERE user_id = $1 AND status = 'VESTING' ORDER BY reward_id`, userID); err != nil {
+		return nil, nil, err
+	}
+	res := []VestingRelease{}
+	for _, id := range ids {
+		rel, _, _, err := closeVesting(ctx, tx, id, VestingLapsed, accountForfeited, "lapsed unvested shares")
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := enqueueOutbox(ctx, tx, events.RewardLapsed, map[string]interface{}{
+			"reward_id": rel.RewardID,
+			"user_id":   rel.UserID,
+			"symbol":    rel.Symbol,
+			"quantity":  rel.Quantity.StringFixed(6),
+		}); err != nil {
+			return nil, nil, err
+		}
+		res = append(res, rel)
+	}
+
+	rejected := []string{}
+	if err := tx.SelectContext(ctx, &rejected, `SELECT id FROM rewards WHERE user_id = $1 AND status = 'PENDING_APPROVAL' AND vesting_months IS NOT NULL ORDER BY id FOR UPDATE`, userID); err != nil {
+		return nil, nil, err
+	}
+	for _, id := range rejected {
+		if err := rejectReward(ctx, tx, id, actorFrom(ctx).Subject, "user churned"); err != nil {
+			return nil, nil, err
+		}
+	}
+	return res, rejected, tx.Commit()
+}
+
+// unvestedBySymbol returns the user's still-unvested quantity per symbol.
+func (r *Repo) unvestedBySymbol(ctx context.Context, userID string) (map[string]decimal.Decimal, error) {
+	rows, err := r.db.QueryxContext(ctx, `
+		SELECT rv.symbol, SUM(rw.quantity - rv.vested_quantity)::text
+		FROM reward_vesting rv
+		JOIN rewards rw ON rw.id = rv.reward_id
+		WHERE rv.user_id = $1 AND rv.status = 'VESTING'
+		GROUP BY rv.symbol`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := map[string]decimal.Decimal{}
+	for rows.Next() {
+		var sym, qty string
+		if err := rows.Scan(&sym, &qty); err != nil {
+			return nil, err
+		}
+		res[sym], _ = decimal.NewFromString(qty)
+	}
+	return res, rows.Err()
+}
+
+// vestingGrants loads the user's completed rewards that carry a vesting
+// schedule, for historical valuations.
+func (r *Repo) vestingGrants(ctx context.Context, userID string) ([]vestingGrant, error) {
+	grants := []vestingGrant{}
+	err := r.db.SelectContext(ctx, &grants, `
+		SELECT `+vestingGrantColumns+`
+		FROM reward_vesting rv
+		JOIN rewards rw ON rw.id = rv.reward_id
+		WHERE rv.user_id = $1 AND rw.status = 'COMPLETED'`, userID)
+	return grants, err
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"stocky/internal/vesting"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+func TestVestingLifecycle(t *testing.T) {
+	db := setupDB(t)
+	r := New(db, logrus.New())
+	ctx := context.Background()
+
+	userID := "test-vesting-user"
+	symbol := "INFY"
+	idKey := "test-vesting-key"
+	if _, err := db.Exec("INSERT INTO users (id, name) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING", userID, "Test Vesting User"); err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	_, _ = db.Exec("DELETE FROM reward_vesting WHERE user_id = $1", userID)
+	_, _ = db.Exec("DELETE FROM ledger_entries WHERE reward_id IN (SELECT id FROM rewards WHERE idempotency_key = $1)", idKey)
+	_, _ = db.Exec("DELETE FROM rewards WHERE idempotency_key = $1", idKey)
+	_, _ = db.Exec("DELETE FROM holdings WHERE user_id = $1", userID)
+
+	granted := time.Now().UTC().AddDate(0, -4, 0).Add(-time.Hour)
+	_, _, err := r.CreateReward(ctx, RewardInput{
+		UserID: userID, Symbol: symbol, Quantity: decimal.NewFromInt(12), Timestamp: granted,
+		IdempotencyKey: idKey, Source: "referral", Price: decimal.NewFromInt(1500),
+		Vesting: &vesting.Schedule{CliffMonths: 3, Months: 12},
+	})
+	if err != nil {
+		t.Fatalf("create reward failed: %v", err)
+	}
+
+	holdings, err := r.GetHoldings(ctx, userID)
+	if err != nil {
+		t.Fatalf("get holdings failed: %v", err)
+	}
+	if len(holdings) != 0 {
+		t.Fatalf("expected nothing vested at grant, got %v", holdings)
+	}
+
+	if _, err := r.VestDue(ctx, time.Now().UTC(), 1000); err != nil {
+		t.Fatalf("vest due failed: %v", err)
+	}
+	items, _, err := r.GetPortfolio(ctx, userID)
+	if err != nil {
+		t.Fatalf("get portfolio failed: %v", err)
+	}
+	if len(items) != 1 || !items[0].VestedQuantity.Equal(decimal.NewFromInt(4)) || !items[0].UnvestedQuantity.Equal(decimal.NewFromInt(8)) {
+		t.Fatalf("expected 4 vested and 8 unvested, got %+v", items)
+	}
+
+	pendingKey := idKey + "-pending"
+	_, _ = db.Exec("DELETE FROM approvals WHERE reward_id IN (SELECT id FROM rewards WHERE idempotency_key = $1)", pendingKey)
+	_, _ = db.Exec("DELETE FROM rewards WHERE idempotency_key = $1", pendingKey)
+	pendingID, _, err := r.CreateReward(ctx, RewardInput{
+		UserID: userID, Symbol: symbol, Quantity: decimal.NewFromInt(12), Timestamp: granted,
+		IdempotencyKey: pendingKey, Source: "referral", Price: decimal.NewFromInt(1500),
+		Vesting: &vesting.Schedule{CliffMonths: 3, Months: 12}, RequiresApproval: true, RequestedBy: "maker",
+	})
+	if err != nil {
+		t.Fatalf("create pending reward failed: %v", err)
+	}
+
+	lapsed, rejected, err := r.LapseUnvested(ctx, userID)
+	if err != nil {
+		t.Fatalf("lapse failed: %v", err)
+	}
+	if len(rejected) != 1 || rejected[0] != pendingID {
+		t.Fatalf("expected the pending vesting grant to be rejected, got %v", rejected)
+	}
+	if _, err := r.ApproveReward(ctx, pendingID, "checker", ""); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("approving a churned user's grant should fail with ErrNotPending, got %v", err)
+	}
+	if len(lapsed) != 1 || !lapsed[0].Quantity.Equal(decimal.NewFromInt(8)) {
+		t.Fatalf("expected 8 lapsed, got %+v", lapsed)
+	}
+	items, _, err = r.GetPortfolio(ctx, userID)
+	if err != nil {
+		t.Fatalf("get portfolio failed: %v", err)
+	}
+	if len(items) != 1 || !items[0].Quantity.Equal(decimal.NewFromInt(4)) || !items[0].UnvestedQuantity.IsZero() {
+		t.Fatalf("expected only vested shares after churn, got %+v", items)
+	}
+}
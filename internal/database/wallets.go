@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"stocky/internal/events"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+type Sale struct {
+	ID             string          `db:"id" json:"id"`
+	UserID         string          `db:"user_id" json:"user_id"`
+	Symbol         string          `db:"symbol" json:"symbol"`
+	Quantity       decimal.Decimal `db:"quantity" json:"quantity"`
+	PriceINR       decimal.Decimal `db:"price_inr" json:"price_inr"`
+	GrossINR       decimal.Decimal `db:"gross_inr" json:"gross_inr"`
+	FeesINR        decimal.Decimal `db:"fees_inr" json:"fees_inr"`
+	NetINR         decimal.Decimal `db:"net_inr" json:"net_inr"`
+	InitiatedBy    string          `db:"initiated_by" json:"initiated_by"`
+	IdempotencyKey *string         `db:"idempotency_key" json:"idempotency_key,omitempty"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+}
+
+const saleColumns = `id, user_id, symbol, quantity, price_inr, gross_inr, fees_inr, net_inr, initiated_by, idempotency_key, created_at`
+
+type WalletTransaction struct {
+	AmountINR   decimal.Decimal `db:"amount_inr" json:"amount_inr"`
+	BalanceINR  decimal.Decimal `db:"balance_inr" json:"balance_inr"`
+	RefType     string          `db:"ref_type" json:"ref_type"`
+	RefID       string          `db:"ref_id" json:"ref_id"`
+	Description *string         `db:"description" json:"description,omitempty"`
+	CreatedAt   time.Time       `db:"created_at" json:"created_at"`
+}
+
+// CreateSale sells s.Quantity of the user's available shares back to the
+// company and credits s.NetINR to their wallet. The caller prices the sale;
+// a replayed idempotency key returns the user's original sale with
+// created=false. Keys are scoped to the user.
+func (r *Repo) CreateSale(ctx context.Context, s Sale) (Sale, bool, error) {
+	var res Sale
+	if s.IdempotencyKey != nil {
+		err := r.db.GetContext(ctx, &res, `SELECT `+saleColumns+` FROM sales WHERE user_id = $1 AND idempotency_key = $2`, s.UserID, *s.IdempotencyKey)
+		if err == nil {
+			return res, false, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return res, false, err
+		}
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return res, false, err
+	}
+	defer tx.Rollback()
+
+	held, err := lockHoldings(ctx, tx, s.Symbol, s.UserID)
+	if err != nil {
+		return res, false, err
+	}
+	if held[s.UserID].LessThan(s.Quantity) {
+		return res, false, ErrInsufficientHoldings
+	}
+
+	if err := tx.GetContext(ctx, &res, `INSERT INTO sales (user_id, symbol, quantity, price_inr, gross_inr, fees_inr, net_inr, initiated_by, idempotency_key) VALUES ($1, $2, $3::numeric, $4::numeric, $5::numeric, $6::numeric, $7::numeric, $8, $9) RETURNING `+saleColumns,
+		s.UserID, s.Symbol, s.Quantity.StringFixed(6), s.PriceINR.StringFixed(4), s.GrossINR.StringFixed(4), s.FeesINR.StringFixed(4), s.NetINR.StringFixed(4), s.InitiatedBy, s.IdempotencyKey); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" && s.IdempotencyKey != nil {
+			tx.Rollback()
+			if err := r.db.GetContext(ctx, &res, `SELECT `+saleColumns+` FROM sales WHERE user_id = $1 AND idempotency_key = $2`, s.UserID, *s.IdempotencyKey); err == nil {
+				return res, false, nil
+			}
+		}
+		return res, false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE holdings SET quantity = quantity - $3::numeric, last_updated = now() WHERE user_id = $1 AND symbol = $2`, s.UserID, s.Symbol, s.Quantity.String()); err != nil {
+		return res, false, err
+	}
+	uses, err := consumeLots(ctx, tx, s.UserID, s.Symbol, s.Quantity, nil)
+	if err != nil {
+		return res, false, err
+	}
+	if err := recordDisposals(ctx, tx, res, uses); err != nil {
+		return res, false, err
+	}
+	var balance decimal.Decimal
+	if err := tx.GetContext(ctx, &balance, `INSERT INTO wallets (user_id, balance_inr, updated_at) VALUES ($1, $2::numeric, now()) ON CONFLICT (user_id) DO UPDATE SET balance_inr = wallets.balance_inr + $2::numeric, updated_at = now() RETURNING balance_inr`,
+		s.UserID, res.NetINR.StringFixed(4)); err != nil {
+		return res, false, err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO wallet_transactions (user_id, amount_inr, balance_inr, ref_type, ref_id, description) VALUES ($1, $2::numeric, $3::numeric, 'sale', $4, $5)`,
+		s.UserID, res.NetINR.StringFixed(4), balance.StringFixed(4), res.ID, "sold "+res.Quantity.String()+" "+res.Symbol); err != nil {
+		return res, false, err
+	}
+
+	// The company buys the shares back into inventory and pays the gross
+	// proceeds into the wallet, then keeps the fees out of it.
+	wallet := "wallet:" + s.UserID
+	if err := postRefEntry(ctx, tx, "sale", res.ID, "user_holdings:"+s.UserID, "stock_inventory", res.GrossINR, &res.Symbol, &res.Quantity, "sell-back shares"); err != nil {
+		return res, false, err
+	}
+	if err := postRefEntry(ctx, tx, "sale", res.ID, "company_cash", wallet, res.GrossINR, nil, nil, "sell-back proceeds"); err != nil {
+		return res, false, err
+	}
+	if res.FeesINR.IsPositive() {
+		if err := postRefEntry(ctx, tx, "sale", res.ID, wallet, "fee_income", res.FeesINR, nil, nil, "sell-back fees"); err != nil {
+			return res, false, err
+		}
+	}
+	if err := enqueueOutbox(ctx, tx, events.SaleDone, map[string]interface{}{
+		"sale_id":  res.ID,
+		"user_id":  res.UserID,
+		"symbol":   res.Symbol,
+		"quantity": res.Quantity.StringFixed(6),
+		"net_inr":  res.NetINR.StringFixed(4),
+	}); err != nil {
+		return res, false, err
+	}
+	return res, true, tx.Commit()
+}
+
+// GetWallet returns a user's cash balance, zero if they never sold, and
+// their most recent wallet transactions, newest first.
+func (r *Repo) GetWallet(ctx context.Context, userID string, limit int) (decimal.Decimal, []WalletTransaction, error) {
+	var balance decimal.Decimal
+	err := r.db.GetContext(ctx, &balance, `SELECT balance_inr FROM wallets WHERE user_id = $1`, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return balance, nil, err
+	}
+	history := []WalletTransaction{}
+	err = r.db.SelectContext(ctx, &history, `SELECT amount_inr, balance_inr, ref_type, ref_id, description, created_at FROM wallet_transactions WHERE user_id = $1 ORDER BY id DESC LIMIT $2`, userID, limit)
+	return balance, history, err
+}
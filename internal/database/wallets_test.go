@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+func TestCreateSale(t *testing.T) {
+	db := setupDB(t)
+	r := New(db, logrus.New())
+	ctx := context.Background()
+
+	user, other, symbol := "test-sale-user", "test-sale-other", "INFY"
+	for _, u := range []string{user, other} {
+		if _, err := db.Exec("INSERT INTO users (id, name) VALUES ($1, $1) ON CONFLICT (id) DO NOTHING", u); err != nil {
+			t.Fatalf("create user failed: %v", err)
+		}
+		_, _ = db.Exec("DELETE FROM ledger_entries WHERE ref_type = 'sale' AND ref_id IN (SELECT id FROM sales WHERE user_id = $1)", u)
+		_, _ = db.Exec("DELETE FROM wallet_transactions WHERE user_id = $1", u)
+		_, _ = db.Exec("DELETE FROM wallets WHERE user_id = $1", u)
+		_, _ = db.Exec("DELETE FROM lot_disposals WHERE user_id = $1", u)
+		_, _ = db.Exec("DELETE FROM sales WHERE user_id = $1", u)
+		_, _ = db.Exec("DELETE FROM holdings WHERE user_id = $1", u)
+		_, _ = db.Exec("DELETE FROM holding_lots WHERE user_id = $1", u)
+		if _, err := db.Exec("INSERT INTO holdings (user_id, symbol, quantity, reserved_quantity) VALUES ($1, $2, 5, 1)", u, symbol); err != nil {
+			t.Fatalf("seed holdings failed: %v", err)
+		}
+		if err := seedLot(db, u, symbol, 5); err != nil {
+			t.Fatalf("seed lot failed: %v", err)
+		}
+	}
+
+	key := "test-sale-key"
+	s := Sale{UserID: user, Symbol: symbol, Quantity: decimal.NewFromInt(2), PriceINR: decimal.NewFromInt(1500),
+		GrossINR: decimal.NewFromInt(3000), FeesINR: decimal.NewFromInt(30), NetINR: decimal.NewFromInt(2970), InitiatedBy: user, IdempotencyKey: &key}
+	first, created, err := r.CreateSale(ctx, s)
+	if err != nil || !created {
+		t.Fatalf("sale failed: created=%v err=%v", created, err)
+	}
+	again, created, err := r.CreateSale(ctx, s)
+	if err != nil || created || again.ID != first.ID {
+		t.Fatalf("replay should return the original sale, got %+v created=%v err=%v", again, created, err)
+	}
+	theirs := s
+	theirs.UserID, theirs.InitiatedBy = other, other
+	if sale, created, err := r.CreateSale(ctx, theirs); err != nil || !created || sale.ID == first.ID {
+		t.Fatalf("same key from another user should create a sale, got %+v created=%v err=%v", sale, created, err)
+	}
+
+	// Three shares remain, one of them reserved.
+	s.IdempotencyKey = nil
+	s.Quantity = decimal.NewFromInt(3)
+	if _, _, err := r.CreateSale(ctx, s); !errors.Is(err, ErrInsufficientHoldings) {
+		t.Fatalf("expected ErrInsufficientHoldings, got %v", err)
+	}
+
+	balance, history, err := r.GetWallet(ctx, user, 10)
+	if err != nil {
+		t.Fatalf("get wallet failed: %v", err)
+	}
+	if !balance.Equal(decimal.NewFromInt(2970)) || len(history) != 1 || history[0].RefID != first.ID {
+		t.Fatalf("expected balance 2970 from one sale, got %s %+v", balance, history)
+	}
+	h, err := r.GetHoldings(ctx, user)
+	if err != nil || len(h) != 1 || !h[0].Quantity.Equal(decimal.NewFromInt(3)) {
+		t.Fatalf("expected 3 %s left, got %v (err %v)", symbol, h, err)
+	}
+}
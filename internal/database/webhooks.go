@@ -0,0 +1,201 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	DeliveryPending   = "PENDING"
+	DeliveryDelivered = "DELIVERED"
+	DeliveryFailed    = "FAILED"
+)
+
+type WebhookSubscription struct {
+	ID         string         `db:"id" json:"id"`
+	URL        string         `db:"url" json:"url"`
+	Secret     string         `db:"secret" json:"-"`
+	EventTypes pq.StringArray `db:"event_types" json:"event_types"`
+	Active     bool           `db:"active" json:"active"`
+	CreatedAt  time.Time      `db:"created_at" json:"created_at"`
+}
+
+// PendingDelivery is a claimed delivery with everything needed to send it.
+type PendingDelivery struct {
+	ID        string          `db:"id"`
+	OutboxID  int64           `db:"outbox_id"`
+	Attempts  int             `db:"attempts"`
+	URL       string          `db:"url"`
+	Secret    string          `db:"secret"`
+	EventType string          `db:"event_type"`
+	Payload   json.RawMessage `db:"payload"`
+	CreatedAt time.Time       `db:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             string            `db:"id" json:"id"`
+	OutboxID       int64             `db:"outbox_id" json:"outbox_id"`
+	EventType      string            `db:"event_type" json:"event_type"`
+	Status         string            `db:"status" json:"status"`
+	Attempts       int               `db:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time         `db:"next_attempt_at" json:"next_attempt_at"`
+	LastStatusCode *int64            `db:"last_status_code" json:"last_status_code,omitempty"`
+	LastError      *string           `db:"last_error" json:"last_error,omitempty"`
+	DeliveredAt    *time.Time        `db:"delivered_at" json:"delivered_at,omitempty"`
+	History        []DeliveryAttempt `db:"-" json:"history"`
+}
+
+type DeliveryAttempt struct {
+	Attempt     int       `db:"attempt" json:"attempt"`
+	StatusCode  *int64    `db:"status_code" json:"status_code,omitempty"`
+	Error       *string   `db:"error" json:"error,omitempty"`
+	DurationMS  int       `db:"duration_ms" json:"duration_ms"`
+	AttemptedAt time.Time `db:"attempted_at" json:"attempted_at"`
+}
+
+// enqueueOutbox records an event inside the caller's transaction so it is
+// only dispatched if the surrounding state change commits.
+func enqueueOutbox(ctx context.Context, tx *sqlx.Tx, eventType string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO webhook_outbox (event_type, payload) VALUES ($1, $2::jsonb)`, eventType, string(b))
+	return err
+}
+
+func (r *Repo) CreateWebhookSubscription(ctx context.Context, url, secret string, eventTypes []string) (WebhookSubscription, error) {
+	var s WebhookSubscription
+	err := r.db.GetContext(ctx, &s, `INSERT INTO webhook_subscriptions (url, secret, event_types) VALUES ($1, $2, $3) RETURNING id, url, secret, event_types, active, created_at`, url, secret, pq.StringArray(eventTypes))
+	return s, err
+}
+
+func (r *Repo) ListWebhookSubscriptions(ctx context.Context) ([]WebhookSubscription, error) {
+	res := []WebhookSubscription{}
+	err := r.db.SelectContext(ctx, &res, `SELECT id, url, secret, event_types, active, created_at FROM webhook_subscriptions ORDER BY created_at ASC`)
+	return res, err
+}
+
+// FanOutOutbox turns undispatched outbox events into one delivery per
+// matching active subscription and returns the number of events processed.
+func (r *Repo) FanOutOutbox(ctx context.Context, limit int) (int, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var ids []int64
+	if err := tx.SelectContext(ctx, &ids, `SELECT id FROM webhook_outbox WHERE fanned_out_at IS NULL ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED`, limit); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	arr := pq.Int64Array(ids)
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (outbox_id, subscription_id)
+		SELECT o.id, s.id
+		FROM webhook_outbox o
+		JOIN webhook_subscriptions s ON s.active AND o.event_type = ANY(s.event_types)
+		WHERE o.id = ANY($1)
+		ON CONFLICT (outbox_id, subscription_id) DO NOTHING`, arr); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE webhook_outbox SET fanned_out_at = now() WHERE id = ANY($1)`, arr); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// ClaimDueDeliveries leases up to limit due deliveries by pushing their
+// next_attempt_at forward, so concurrent dispatchers do not send them twice.
+func (r *Repo) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]PendingDelivery, error) {
+	res := []PendingDelivery{}
+	err := r.db.SelectContext(ctx, &res, `
+		WITH claimed AS (
+			UPDATE webhook_deliveries SET next_attempt_at = now() + $2 * interval '1 millisecond'
+			WHERE id IN (
+				SELECT id FROM webhook_deliveries
+				WHERE status = 'PENDING' AND next_attempt_at <= now()
+				ORDER BY next_attempt_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED)
+			RETURNING id, outbox_id, subscription_id, attempts)
+		SELECT c.id, c.outbox_id, c.attempts, s.url, s.secret, o.event_type, o.payload, o.created_at
+		FROM claimed c
+		JOIN webhook_subscriptions s ON s.id = c.subscription_id
+		JOIN webhook_outbox o ON o.id = c.outbox_id`, limit, lease.Milliseconds())
+	return res, err
+}
+
+// RecordDeliveryAttempt logs one send attempt and moves the delivery to its
+// next state. A zero retryAt with success false marks the delivery FAILED.
+func (r *Repo) RecordDeliveryAttempt(ctx context.Context, deliveryID string, attempt, statusCode int, sendErr string, dur time.Duration, success bool, retryAt time.Time) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	code := sql.NullInt64{Int64: int64(statusCode), Valid: statusCode > 0}
+	errMsg := sql.NullString{String: sendErr, Valid: sendErr != ""}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO webhook_delivery_attempts (delivery_id, attempt, status_code, error, duration_ms) VALUES ($1, $2, $3, $4, $5)`, deliveryID, attempt, code, errMsg, dur.Milliseconds()); err != nil {
+		return err
+	}
+
+	switch {
+	case success:
+		_, err = tx.ExecContext(ctx, `UPDATE webhook_deliveries SET status = 'DELIVERED', attempts = $2, last_status_code = $3, last_error = NULL, delivered_at = now() WHERE id = $1`, deliveryID, attempt, code)
+	case retryAt.IsZero():
+		_, err = tx.ExecContext(ctx, `UPDATE webhook_deliveries SET status = 'FAILED', attempts = $2, last_status_code = $3, last_error = $4 WHERE id = $1`, deliveryID, attempt, code, errMsg)
+	default:
+		_, err = tx.ExecContext(ctx, `UPDATE webhook_deliveries SET attempts = $2, last_status_code = $3, last_error = $4, next_attempt_at = $5 WHERE id = $1`, deliveryID, attempt, code, errMsg, retryAt)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListWebhookDeliveries returns a subscription's latest deliveries with their
+// attempts. It returns sql.ErrNoRows for unknown or malformed subscription
+// ids.
+func (r *Repo) ListWebhookDeliveries(ctx context.Context, subscriptionID string, limit int) ([]WebhookDelivery, error) {
+	if !ValidUUID(subscriptionID) {
+		return nil, sql.ErrNoRows
+	}
+	var exists bool
+	if err := r.db.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM webhook_subscriptions WHERE id = $1)`, subscriptionID); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+	res := []WebhookDelivery{}
+	if err := r.db.SelectContext(ctx, &res, `
+		SELECT d.id, d.outbox_id, o.event_type, d.status, d.attempts, d.next_attempt_at, d.last_status_code, d.last_error, d.delivered_at
+		FROM webhook_deliveries d
+		JOIN webhook_outbox o ON o.id = d.outbox_id
+		WHERE d.subscription_id = $1
+		ORDER BY d.created_at DESC
+		LIMIT $2`, subscriptionID, limit); err != nil {
+		return nil, err
+	}
+	for i := range res {
+		attempts := []DeliveryAttempt{}
+		if err := r.db.SelectContext(ctx, &attempts, `SELECT attempt, status_code, error, COALESCE(duration_ms, 0) AS duration_ms, attempted_at FROM webhook_delivery_attempts WHERE delivery_id = $1 ORDER BY attempt ASC`, res[i].ID); err != nil {
+			return nil, err
+		}
+		res[i].History = attempts
+	}
+	return res, nil
+}
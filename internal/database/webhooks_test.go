@@ -0,0 +1,31 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestListWebhookDeliveriesUnknownSubscription(t *testing.T) {
+	db := setupDB(t)
+	r := New(db, logrus.New())
+	ctx := context.Background()
+
+	sub, err := r.CreateWebhookSubscription(ctx, "https://example.com/hook", "test-secret", []string{"reward.created"})
+	if err != nil {
+		t.Fatalf("create subscription failed: %v", err)
+	}
+	// Keep the test subscription out of real fan-out.
+	_, _ = db.Exec("UPDATE webhook_subscriptions SET active = false WHERE id = $1", sub.ID)
+	if rows, err := r.ListWebhookDeliveries(ctx, sub.ID, 10); err != nil || len(rows) != 0 {
+		t.Fatalf("expected no deliveries for a new subscription, got %+v (err %v)", rows, err)
+	}
+	for _, id := range []string{"not-a-uuid", "00000000-0000-0000-0000-000000000000"} {
+		if _, err := r.ListWebhookDeliveries(ctx, id, 10); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("%s: expected sql.ErrNoRows, got %v", id, err)
+		}
+	}
+}
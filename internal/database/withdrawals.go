@@ -0,0 +1,214 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"stocky/internal/events"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	WithdrawalRequested  = "REQUESTED"
+	WithdrawalProcessing = "PROCESSING"
+	WithdrawalSettled    = "SETTLED"
+	WithdrawalFailed     = "FAILED"
+)
+
+// withdrawalTransitions lists the states each state may move to. SETTLED and
+// FAILED are final.
+var withdrawalTransitions = map[string][]string{
+	WithdrawalRequested:  {WithdrawalProcessing, WithdrawalFailed},
+	WithdrawalProcessing: {WithdrawalSettled, WithdrawalFailed},
+}
+
+func CanTransitionWithdrawal(from, to string) bool {
+	for _, s := range withdrawalTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionError reports a state change the withdrawal state machine does
+// not allow.
+type TransitionError struct {
+	From, To string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("withdrawal cannot move from %s to %s", e.From, e.To)
+}
+
+type Withdrawal struct {
+	ID             string          `db:"id" json:"id"`
+	UserID         string          `db:"user_id" json:"user_id"`
+	Symbol         string          `db:"symbol" json:"symbol"`
+	Quantity       decimal.Decimal `db:"quantity" json:"quantity"`
+	DematAccount   string          `db:"demat_account" json:"demat_account"`
+	Status         string          `db:"status" json:"status"`
+	IdempotencyKey *string         `db:"idempotency_key" json:"idempotency_key,omitempty"`
+	ExternalRef    *string         `db:"external_ref" json:"external_ref,omitempty"`
+	FailureReason  *string         `db:"failure_reason" json:"failure_reason,omitempty"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time       `db:"updated_at" json:"updated_at"`
+}
+
+const withdrawalColumns = `id, user_id, symbol, quantity, demat_account, status, idempotency_key, external_ref, failure_reason, created_at, updated_at`
+
+type WithdrawalTransition struct {
+	FromStatus *string   `db:"from_status" json:"from_status,omitempty"`
+	ToStatus   string    `db:"to_status" json:"to_status"`
+	Actor      string    `db:"actor" json:"actor"`
+	Note       *string   `db:"note" json:"note,omitempty"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+func recordWithdrawalTransition(ctx context.Context, tx *sqlx.Tx, w Withdrawal, from *string, actor, note string) error {
+	if _, err := tx.ExecContext(ctx, `INSERT INTO withdrawal_transitions (withdrawal_id, from_status, to_status, actor, note) VALUES ($1, $2, $3, $4, NULLIF($5, ''))`, w.ID, from, w.Status, actor, note); err != nil {
+		return err
+	}
+	return enqueueOutbox(ctx, tx, events.WithdrawalState, map[string]interface{}{
+		"withdrawal_id": w.ID,
+		"user_id":       w.UserID,
+		"symbol":        w.Symbol,
+		"quantity":      w.Quantity.StringFixed(6),
+		"status":        w.Status,
+	})
+}
+
+// RequestWithdrawal reserves w.Quantity of the user's holdings for a
+// transfer-out to their demat account. Reserved shares cannot be transferred
+// or withdrawn again until the withdrawal settles or fails. Replaying an
+// idempotency key returns the user's original withdrawal with created=false;
+// keys are scoped to the user.
+func (r *Repo) RequestWithdrawal(ctx context.Context, w Withdrawal, actor string) (Withdrawal, bool, error) {
+	var res Withdrawal
+	if w.IdempotencyKey != nil {
+		err := r.db.GetContext(ctx, &res, `SELECT `+withdrawalColumns+` FROM withdrawals WHERE user_id = $1 AND idempotency_key = $2`, w.UserID, *w.IdempotencyKey)
+		if err == nil {
+			return res, false, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return res, false, err
+		}
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return res, false, err
+	}
+	defer tx.Rollback()
+
+	var qty, reserved decimal.Decimal
+	err = tx.QueryRowContext(ctx, `SELECT quantity, reserved_quantity FROM holdings WHERE user_id = $1 AND symbol = $2 FOR UPDATE`, w.UserID, w.Symbol).Scan(&qty, &reserved)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return res, false, err
+	}
+	if qty.Sub(reserved).LessThan(w.Quantity) {
+		return res, false, ErrInsufficientHoldings
+	}
+
+	if err := tx.GetContext(ctx, &res, `INSERT INTO withdrawals (user_id, symbol, quantity, demat_account, idempotency_key) VALUES ($1, $2, $3::numeric, $4, $5) RETURNING `+withdrawalColumns,
+		w.UserID, w.Symbol, w.Quantity.StringFixed(6), w.DematAccount, w.IdempotencyKey); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" && w.IdempotencyKey != nil {
+			tx.Rollback()
+			if err := r.db.GetContext(ctx, &res, `SELECT `+withdrawalColumns+` FROM withdrawals WHERE user_id = $1 AND idempotency_key = $2`, w.UserID, *w.IdempotencyKey); err == nil {
+				return res, false, nil
+			}
+		}
+		return res, false, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE holdings SET reserved_quantity = reserved_quantity + $3::numeric, last_updated = now() WHERE user_id = $1 AND symbol = $2`, w.UserID, w.Symbol, w.Quantity.String()); err != nil {
+		return res, false, err
+	}
+	if err := recordWithdrawalTransition(ctx, tx, res, nil, actor, ""); err != nil {
+		return res, false, err
+	}
+	return res, true, tx.Commit()
+}
+
+// AdvanceWithdrawal moves a withdrawal to status. Settling removes the
+// reserved shares from holdings and posts the transfer-out to the ledger;
+// failing releases the reservation. Unknown or malformed ids return
+// sql.ErrNoRows.
+func (r *Repo) AdvanceWithdrawal(ctx context.Context, id, status, actor, note, externalRef string) (Withdrawal, error) {
+	var w Withdrawal
+	if !ValidUUID(id) {
+		return w, sql.ErrNoRows
+	}
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return w, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.GetContext(ctx, &w, `SELECT `+withdrawalColumns+` FROM withdrawals WHERE id = $1 FOR UPDATE`, id); err != nil {
+		return w, err
+	}
+	from := w.Status
+	if !CanTransitionWithdrawal(from, status) {
+		return w, &TransitionError{From: from, To: status}
+	}
+
+	switch status {
+	case WithdrawalSettled:
+		if _, err := tx.ExecContext(ctx, `UPDATE holdings SET quantity = quantity - $3::numeric, reserved_quantity = reserved_quantity - $3::numeric, last_updated = now() WHERE user_id = $1 AND symbol = $2`, w.UserID, w.Symbol, w.Quantity.String()); err != nil {
+			return w, err
+		}
+		if _, err := consumeLots(ctx, tx, w.UserID, w.Symbol, w.Quantity, nil); err != nil {
+			return w, err
+		}
+		var price decimal.Decimal
+		if err := tx.QueryRowContext(ctx, `SELECT price_inr FROM price_history WHERE symbol = $1 ORDER BY timestamp DESC LIMIT 1`, w.Symbol).Scan(&price); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return w, err
+		}
+		if err := postRefEntry(ctx, tx, "withdrawal", w.ID, "user_holdings:"+w.UserID, "demat_transfer_out", w.Quantity.Mul(price), &w.Symbol, &w.Quantity, "demat transfer-out"); err != nil {
+			return w, err
+		}
+	case WithdrawalFailed:
+		if _, err := tx.ExecContext(ctx, `UPDATE holdings SET reserved_quantity = reserved_quantity - $3::numeric, last_updated = now() WHERE user_id = $1 AND symbol = $2`, w.UserID, w.Symbol, w.Quantity.String()); err != nil {
+			return w, err
+		}
+	}
+
+	failure := ""
+	if status == WithdrawalFailed {
+		failure = note
+	}
+	if err := tx.GetContext(ctx, &w, `UPDATE withdrawals SET status = $2, external_ref = COALESCE(NULLIF($3, ''), external_ref), failure_reason = NULLIF($4, ''), updated_at = now() WHERE id = $1 RETURNING `+withdrawalColumns,
+		id, status, externalRef, failure); err != nil {
+		return w, err
+	}
+	if err := recordWithdrawalTransition(ctx, tx, w, &from, actor, note); err != nil {
+		return w, err
+	}
+	return w, tx.Commit()
+}
+
+func (r *Repo) GetWithdrawal(ctx context.Context, id string) (Withdrawal, []WithdrawalTransition, error) {
+	var w Withdrawal
+	if !ValidUUID(id) {
+		return w, nil, sql.ErrNoRows
+	}
+	if err := r.db.GetContext(ctx, &w, `SELECT `+withdrawalColumns+` FROM withdrawals WHERE id = $1`, id); err != nil {
+		return w, nil, err
+	}
+	history := []WithdrawalTransition{}
+	err := r.db.SelectContext(ctx, &history, `SELECT from_status, to_status, actor, note, created_at FROM withdrawal_transitions WHERE withdrawal_id = $1 ORDER BY id`, id)
+	return w, history, err
+}
+
+// ListWithdrawals filters by user and status; empty values match all.
+func (r *Repo) ListWithdrawals(ctx context.Context, userID, status string, limit int) ([]Withdrawal, error) {
+	res := []Withdrawal{}
+	err := r.db.SelectContext(ctx, &res, `SELECT `+withdrawalColumns+` FROM withdrawals WHERE ($1 = '' OR user_id = $1) AND ($2 = '' OR status = $2) ORDER BY created_at DESC LIMIT $3`, userID, status, limit)
+	return res, err
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+func TestWithdrawalTransitions(t *testing.T) {
+	allowed := [][2]string{
+		{WithdrawalRequested, WithdrawalProcessing},
+		{WithdrawalRequested, WithdrawalFailed},
+		{WithdrawalProcessing, WithdrawalSettled},
+		{WithdrawalProcessing, WithdrawalFailed},
+	}
+	for _, tr := range allowed {
+		if !CanTransitionWithdrawal(tr[0], tr[1]) {
+			t.Errorf("%s -> %s should be allowed", tr[0], tr[1])
+		}
+	}
+	denied := [][2]string{
+		{WithdrawalRequested, WithdrawalSettled},
+		{WithdrawalSettled, WithdrawalFailed},
+		{WithdrawalFailed, WithdrawalProcessing},
+		{WithdrawalProcessing, WithdrawalRequested},
+	}
+	for _, tr := range denied {
+		if CanTransitionWithdrawal(tr[0], tr[1]) {
+			t.Errorf("%s -> %s should be denied", tr[0], tr[1])
+		}
+	}
+}
+
+func TestRequestWithdrawalIdempotencyPerUser(t *testing.T) {
+	db := setupDB(t)
+	r := New(db, logrus.New())
+	ctx := context.Background()
+
+	users, symbol := []string{"test-withdrawal-a", "test-withdrawal-b"}, "TCS"
+	for _, u := range users {
+		if _, err := db.Exec("INSERT INTO users (id, name) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING", u, u); err != nil {
+			t.Fatalf("create user failed: %v", err)
+		}
+		_, _ = db.Exec("DELETE FROM withdrawal_transitions WHERE withdrawal_id IN (SELECT id FROM withdrawals WHERE user_id = $1)", u)
+		_, _ = db.Exec("DELETE FROM withdrawals WHERE user_id = $1", u)
+		_, _ = db.Exec("DELETE FROM holdings WHERE user_id = $1", u)
+		if _, err := db.Exec("INSERT INTO holdings (user_id, symbol, quantity) VALUES ($1, $2, 5)", u, symbol); err != nil {
+			t.Fatalf("seed holdings failed: %v", err)
+		}
+	}
+
+	key := "test-withdrawal-key"
+	w := Withdrawal{UserID: users[0], Symbol: symbol, Quantity: decimal.NewFromInt(2), DematAccount: "IN300000-00000001", IdempotencyKey: &key}
+	first, created, err := r.RequestWithdrawal(ctx, w, users[0])
+	if err != nil || !created {
+		t.Fatalf("request withdrawal failed: created=%v err=%v", created, err)
+	}
+	again, created, err := r.RequestWithdrawal(ctx, w, users[0])
+	if err != nil || created || again.ID != first.ID {
+		t.Fatalf("replay should return the original withdrawal, got %+v created=%v err=%v", again, created, err)
+	}
+
+	w.UserID = users[1]
+	other, created, err := r.RequestWithdrawal(ctx, w, users[1])
+	if err != nil || !created || other.ID == first.ID || other.UserID != users[1] {
+		t.Fatalf("same key from another user should create a withdrawal, got %+v created=%v err=%v", other, created, err)
+	}
+
+	if _, _, err := r.GetWithdrawal(ctx, "not-a-uuid"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for a malformed id, got %v", err)
+	}
+	if _, err := r.AdvanceWithdrawal(ctx, "not-a-uuid", WithdrawalProcessing, users[0], "", ""); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows advancing a malformed id, got %v", err)
+	}
+}
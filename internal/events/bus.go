@@ -0,0 +1,90 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	RewardCreated   = "reward.created"
+	RewardReversed  = "reward.reversed"
+	RewardVested    = "reward.vested"
+	RewardLapsed    = "reward.lapsed"
+	TransferDone    = "transfer.completed"
+	WithdrawalState = "withdrawal.updated"
+	SaleDone        = "sale.completed"
+	PortfolioValued = "portfolio.valued"
+)
+
+type Event struct {
+	Type   string      `json:"type"`
+	UserID string      `json:"user_id"`
+	Time   time.Time   `json:"time"`
+	Data   interface{} `json:"data"`
+}
+
+// Bus is an in-process fan-out of events to subscribers keyed by user id.
+// Slow subscribers drop events instead of blocking publishers.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[string]map[*Subscription]struct{}
+	buffer int
+}
+
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	userID string
+	bus    *Bus
+	once   sync.Once
+}
+
+func NewBus(buffer int) *Bus {
+	if buffer <= 0 {
+		buffer = 16
+	}
+	return &Bus{subs: map[string]map[*Subscription]struct{}{}, buffer: buffer}
+}
+
+func (b *Bus) Subscribe(userID string) *Subscription {
+	ch := make(chan Event, b.buffer)
+	s := &Subscription{C: ch, ch: ch, userID: userID, bus: b}
+	b.mu.Lock()
+	if b.subs[userID] == nil {
+		b.subs[userID] = map[*Subscription]struct{}{}
+	}
+	b.subs[userID][s] = struct{}{}
+	b.mu.Unlock()
+	return s
+}
+
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subs[s.userID], s)
+		if len(s.bus.subs[s.userID]) == 0 {
+			delete(s.bus.subs, s.userID)
+		}
+		s.bus.mu.Unlock()
+		close(s.ch)
+	})
+}
+
+// Publish delivers ev to every subscriber of ev.UserID and reports how many
+// subscribers received it.
+func (b *Bus) Publish(ev Event) int {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	delivered := 0
+	for s := range b.subs[ev.UserID] {
+		select {
+		case s.ch <- ev:
+			delivered++
+		default:
+		}
+	}
+	return delivered
+}
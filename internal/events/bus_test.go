@@ -0,0 +1,43 @@
+package events
+
+import "testing"
+
+func TestBusDeliversOnlyToUser(t *testing.T) {
+	b := NewBus(4)
+	a := b.Subscribe("alice")
+	defer a.Close()
+	o := b.Subscribe("bob")
+	defer o.Close()
+
+	if n := b.Publish(Event{Type: RewardCreated, UserID: "alice"}); n != 1 {
+		t.Fatalf("expected 1 delivery, got %d", n)
+	}
+	ev := <-a.C
+	if ev.Type != RewardCreated || ev.Time.IsZero() {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	select {
+	case ev := <-o.C:
+		t.Fatalf("bob should not receive %+v", ev)
+	default:
+	}
+}
+
+func TestBusDropsWhenSubscriberIsFull(t *testing.T) {
+	b := NewBus(1)
+	s := b.Subscribe("u")
+	b.Publish(Event{Type: RewardCreated, UserID: "u"})
+	if n := b.Publish(Event{Type: RewardReversed, UserID: "u"}); n != 0 {
+		t.Fatalf("expected full subscriber to drop event, got %d deliveries", n)
+	}
+	s.Close()
+	if _, ok := <-s.C; !ok {
+		t.Fatalf("expected buffered event before close")
+	}
+	if _, ok := <-s.C; ok {
+		t.Fatalf("expected channel closed")
+	}
+	if n := b.Publish(Event{Type: RewardCreated, UserID: "u"}); n != 0 {
+		t.Fatalf("expected no subscribers after close, got %d", n)
+	}
+}
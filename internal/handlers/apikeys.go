@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	"stocky/internal/auth"
+	"stocky/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyRequest struct {
+	Partner        string   `json:"partner" binding:"required"`
+	AllowedSources []string `json:"allowed_sources"`
+	AllowedSymbols []string `json:"allowed_symbols"`
+	DailyQuota     int      `json:"daily_quota"`
+}
+
+// newAPIKey returns a random key, its display prefix and its stored hash.
+func newAPIKey() (string, string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	key := "sk_" + hex.EncodeToString(b)
+	return key, key[:11], auth.HashAPIKey(key), nil
+}
+
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var req APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.DailyQuota < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "daily_quota must not be negative"})
+		return
+	}
+	key, prefix, hash, err := newAPIKey()
+	if err != nil {
+		h.log.Errorf("generate api key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal"})
+		return
+	}
+	k, err := h.repo.CreateAPIKey(context.Background(), database.APIKey{
+		Partner:        req.Partner,
+		KeyPrefix:      prefix,
+		KeyHash:        hash,
+		AllowedSources: req.AllowedSources,
+		AllowedSymbols: req.AllowedSymbols,
+		DailyQuota:     req.DailyQuota,
+	})
+	if err != nil {
+		h.log.Errorf("create api key failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create failed"})
+		return
+	}
+	// The plaintext key is only ever returned here and on rotation.
+	c.JSON(http.StatusCreated, gin.H{"api_key": k, "key": key})
+}
+
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.repo.ListAPIKeys(context.Background())
+	if err != nil {
+		h.log.Errorf("list api keys failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+func (h *Handler) RotateAPIKey(c *gin.Context) {
+	key, prefix, hash, err := newAPIKey()
+	if err != nil {
+		h.log.Errorf("generate api key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal"})
+		return
+	}
+	k, err := h.repo.RotateAPIKey(context.Background(), c.Param("id"), prefix, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "api key not found or revoked"})
+		return
+	}
+	if err != nil {
+		h.log.Errorf("rotate api key failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "rotate failed"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"api_key": k, "key": key})
+}
+
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	err := h.repo.RevokeAPIKey(context.Background(), c.Param("id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "api key not found or revoked"})
+		return
+	}
+	if err != nil {
+		h.log.Errorf("revoke api key failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "revoke failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "revoked"})
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"stocky/internal/auth"
+	"stocky/internal/database"
+	"stocky/internal/events"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DecisionRequest struct {
+	Note string `json:"note"`
+}
+
+func (h *Handler) ListApprovals(c *gin.Context) {
+	status := c.DefaultQuery("status", "PENDING")
+	rows, err := h.repo.ListApprovals(context.Background(), status)
+	if err != nil {
+		h.log.Errorf("list approvals failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, rows)
+}
+
+func (h *Handler) decisionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "approval not found"})
+	case errors.Is(err, database.ErrNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrSelfApproval):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		h.log.Errorf("approval decision failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "decision failed"})
+	}
+}
+
+func (h *Handler) ApproveReward(c *gin.Context) {
+	var req DecisionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	p, _ := auth.FromContext(c)
+	id := c.Param("rewardId")
+	ctx := h.auditContext(c)
+	userID, err := h.repo.ApproveReward(ctx, id, p.Subject, req.Note)
+	if err != nil {
+		h.decisionError(c, err)
+		return
+	}
+	h.publish(events.Event{Type: events.RewardCreated, UserID: userID, Data: gin.H{"reward_id": id}})
+	h.publishPortfolio(ctx, userID)
+	c.JSON(http.StatusOK, gin.H{"reward_id": id, "status": "approved"})
+}
+
+func (h *Handler) RejectReward(c *gin.Context) {
+	var req DecisionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	p, _ := auth.FromContext(c)
+	id := c.Param("rewardId")
+	if err := h.repo.RejectReward(h.auditContext(c), id, p.Subject, req.Note); err != nil {
+		h.decisionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reward_id": id, "status": "rejected"})
+}
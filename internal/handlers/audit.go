@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"stocky/internal/auth"
+	"stocky/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+
+	requestIDKey = "request_id"
+)
+
+// RequestID tags each request with the caller's X-Request-ID, or a new one
+// if it sent none, and echoes it in the response so audit events can be
+// traced back to the call that caused them.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			b := make([]byte, 16)
+			_, _ = rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// auditContext carries the caller and request id to the repo so the audit
+// events it records name who made the change.
+func (h *Handler) auditContext(c *gin.Context) context.Context {
+	a := database.Actor{RequestID: c.GetString(requestIDKey)}
+	if p, ok := auth.FromContext(c); ok {
+		a.Subject, a.Role = p.Subject, p.Role
+	}
+	return database.WithActor(context.Background(), a)
+}
+
+// GetAuditEvents lists audit events, newest first, filtered by entity_type,
+// entity_id, actor, action, request_id and from/to dates in the server
+// timezone. Pass the returned next_cursor as ?cursor= for the next page.
+func (h *Handler) GetAuditEvents(c *gin.Context) {
+	f := database.AuditFilter{
+		EntityType: c.Query("entity_type"),
+		EntityID:   c.Query("entity_id"),
+		Actor:      c.Query("actor"),
+		Action:     c.Query("action"),
+		RequestID:  c.Query("request_id"),
+		Limit:      100,
+	}
+	loc := h.repo.Location()
+	from, ok := parseDateQuery(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := parseDateQuery(c, "to")
+	if !ok {
+		return
+	}
+	if !from.IsZero() {
+		f.From = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	}
+	if !to.IsZero() {
+		f.To = time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, loc)
+	}
+	if v := c.Query("cursor"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		f.BeforeID = id
+	}
+	if v := c.Query("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 1000 {
+			f.Limit = n
+		}
+	}
+	rows, next, err := h.repo.ListAuditEvents(context.Background(), f)
+	if err != nil {
+		h.log.Errorf("list audit events failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	resp := gin.H{"events": rows}
+	if next != 0 {
+		resp["next_cursor"] = strconv.FormatInt(next, 10)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// VerifyAuditLog recomputes the hash chain and reports the first event that
+// does not match.
+func (h *Handler) VerifyAuditLog(c *gin.Context) {
+	v, err := h.repo.VerifyAuditChain(context.Background())
+	if err != nil {
+		h.log.Errorf("verify audit chain failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	if !v.Valid {
+		h.log.Errorf("audit chain broken at event %d: %s", *v.BrokenAt, v.Problem)
+	}
+	c.JSON(http.StatusOK, v)
+}
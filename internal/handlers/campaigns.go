@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"stocky/internal/auth"
+	"stocky/internal/database"
+	"stocky/internal/policy"
+	"stocky/internal/vesting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// Event types the rules engine reacts to.
+const (
+	EventOnboarding     = "onboarding"
+	EventReferral       = "referral"
+	EventTradeMilestone = "trade_milestone"
+)
+
+var rewardEventTypes = map[string]bool{
+	EventOnboarding:     true,
+	EventReferral:       true,
+	EventTradeMilestone: true,
+}
+
+type CampaignRequest struct {
+	Name              string     `json:"name" binding:"required"`
+	StartsAt          time.Time  `json:"starts_at" binding:"required"`
+	EndsAt            *time.Time `json:"ends_at"`
+	BudgetINR         string     `json:"budget_inr" binding:"required"`
+	EligibleSymbols   []string   `json:"eligible_symbols"`
+	MaxRewardsPerUser int        `json:"max_rewards_per_user"`
+	MaxINRPerUser     string     `json:"max_inr_per_user"`
+}
+
+func (h *Handler) CreateCampaign(c *gin.Context) {
+	var req CampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	budget, err := decimal.NewFromString(req.BudgetINR)
+	if err != nil || !budget.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "budget_inr must be a positive amount"})
+		return
+	}
+	perUser := decimal.Zero
+	if req.MaxINRPerUser != "" {
+		if perUser, err = decimal.NewFromString(req.MaxINRPerUser); err != nil || perUser.IsNegative() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid max_inr_per_user"})
+			return
+		}
+	}
+	if req.MaxRewardsPerUser < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_rewards_per_user must not be negative"})
+		return
+	}
+	if req.EndsAt != nil && !req.EndsAt.After(req.StartsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be after starts_at"})
+		return
+	}
+	camp, err := h.repo.CreateCampaign(context.Background(), database.Campaign{
+		Name:              req.Name,
+		StartsAt:          req.StartsAt,
+		EndsAt:            req.EndsAt,
+		BudgetINR:         budget,
+		EligibleSymbols:   req.EligibleSymbols,
+		MaxRewardsPerUser: req.MaxRewardsPerUser,
+		MaxINRPerUser:     perUser,
+		Active:            true,
+	})
+	if errors.Is(err, database.ErrCampaignExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.log.Errorf("create campaign failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create failed"})
+		return
+	}
+	c.JSON(http.StatusCreated, camp)
+}
+
+func (h *Handler) ListCampaigns(c *gin.Context) {
+	rows, err := h.repo.ListCampaigns(context.Background())
+	if err != nil {
+		h.log.Errorf("list campaigns failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, rows)
+}
+
+func (h *Handler) GetCampaign(c *gin.Context) {
+	ctx := context.Background()
+	camp, err := h.repo.GetCampaign(ctx, c.Param("id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "campaign not found"})
+		return
+	}
+	if err != nil {
+		h.log.Errorf("get campaign failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	rules, err := h.repo.ListCampaignRules(ctx, camp.ID)
+	if err != nil {
+		h.log.Errorf("list campaign rules failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"campaign": camp, "rules": rules})
+}
+
+type CampaignStatusRequest struct {
+	Active *bool `json:"active" binding:"required"`
+}
+
+func (h *Handler) SetCampaignActive(c *gin.Context) {
+	var req CampaignStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	camp, err := h.repo.SetCampaignActive(context.Background(), c.Param("id"), *req.Active)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "campaign not found"})
+		return
+	}
+	if err != nil {
+		h.log.Errorf("update campaign failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	c.JSON(http.StatusOK, camp)
+}
+
+type CampaignRuleRequest struct {
+	EventType string            `json:"event_type" binding:"required"`
+	MinValue  string            `json:"min_value"`
+	Symbol    string            `json:"symbol" binding:"required"`
+	Quantity  string            `json:"quantity" binding:"required"`
+	Vesting   *vesting.Schedule `json:"vesting"`
+	Priority  int               `json:"priority"`
+}
+
+func (h *Handler) CreateCampaignRule(c *gin.Context) {
+	var req CampaignRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !rewardEventTypes[req.EventType] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown event type " + req.EventType})
+		return
+	}
+	q, err := decimal.NewFromString(req.Quantity)
+	if err != nil || !q.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quantity must be a positive number"})
+		return
+	}
+	minValue := decimal.Zero
+	if req.MinValue != "" {
+		if minValue, err = decimal.NewFromString(req.MinValue); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid min_value"})
+			return
+		}
+	}
+	rule := database.CampaignRule{
+		CampaignID: c.Param("id"),
+		EventType:  req.EventType,
+		MinValue:   minValue,
+		Symbol:     req.Symbol,
+		Quantity:   q,
+		Priority:   req.Priority,
+	}
+	if req.Vesting != nil {
+		if err := req.Vesting.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		rule.CliffMonths, rule.Months = &req.Vesting.CliffMonths, &req.Vesting.Months
+	}
+
+	ctx := h.auditContext(c)
+	camp, err := h.repo.GetCampaign(ctx, rule.CampaignID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "campaign not found"})
+		return
+	}
+	if err != nil {
+		h.log.Errorf("get campaign failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	if !camp.AllowsSymbol(req.Symbol) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "symbol is not eligible for this campaign"})
+		return
+	}
+	stock, err := h.tradableStock(ctx, req.Symbol)
+	if errors.Is(err, errUnknownStock) || errors.Is(err, errInactiveStock) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.log.Errorf("get stock failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	if err := checkLotPrecision(stock, q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	res, err := h.repo.CreateCampaignRule(ctx, rule)
+	if err != nil {
+		h.log.Errorf("create campaign rule failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create failed"})
+		return
+	}
+	c.JSON(http.StatusCreated, res)
+}
+
+// EventRequest is a user action reported to the rules engine. EventID makes
+// retries safe: an event is rewarded at most once.
+type EventRequest struct {
+	EventID   string    `json:"event_id" binding:"required"`
+	UserID    string    `json:"user_id" binding:"required"`
+	Type      string    `json:"type" binding:"required"`
+	Value     string    `json:"value"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// PostEvent runs the rules engine: it tries the matching campaign rules in
+// priority order and books the first reward its campaign can still fund.
+func (h *Handler) PostEvent(c *gin.Context) {
+	var req EventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !rewardEventTypes[req.Type] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown event type " + req.Type})
+		return
+	}
+	value := decimal.Zero
+	if req.Value != "" {
+		var err error
+		if value, err = decimal.NewFromString(req.Value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid value"})
+			return
+		}
+	}
+	now := time.Now().UTC()
+	if req.Timestamp.IsZero() {
+		req.Timestamp = now
+	}
+
+	ctx := h.auditContext(c)
+	campaigns, rules, err := h.repo.ActiveCampaignRules(ctx, req.Type, now)
+	if err != nil {
+		h.log.Errorf("load campaign rules failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	matched := database.MatchRules(campaigns, rules, req.Type, value, now)
+	if len(matched) == 0 {
+		c.JSON(http.StatusOK, gin.H{"rewarded": false, "reason": "no matching rule"})
+		return
+	}
+	names := map[string]string{}
+	for _, camp := range campaigns {
+		names[camp.ID] = camp.Name
+	}
+
+	if err := h.repo.EnsureUserExists(ctx, req.UserID, ""); err != nil {
+		h.log.Warnf("ensure user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal"})
+		return
+	}
+	requestedBy := ""
+	if p, ok := auth.FromContext(c); ok {
+		requestedBy = p.Subject
+	}
+
+	skipped := []gin.H{}
+	for _, rule := range matched {
+		granted, err := h.grant(ctx, database.RewardInput{
+			UserID:         req.UserID,
+			Symbol:         rule.Symbol,
+			Quantity:       rule.Quantity,
+			Timestamp:      req.Timestamp,
+			IdempotencyKey: "event:" + req.EventID,
+			Source:         names[rule.CampaignID],
+			RequestedBy:    requestedBy,
+			Vesting:        rule.Vesting(),
+			CampaignID:     rule.CampaignID,
+		})
+		var violation *policy.Violation
+		if errors.Is(err, database.ErrCampaignUnavailable) || errors.Is(err, database.ErrCampaignBudget) ||
+			errors.Is(err, database.ErrCampaignUserCap) || errors.Is(err, errPriceUnavailable) || errors.As(err, &violation) ||
+			errors.Is(err, errUnknownStock) || errors.Is(err, errInactiveStock) || errors.Is(err, errLotPrecision) {
+			skipped = append(skipped, gin.H{"rule_id": rule.ID, "campaign_id": rule.CampaignID, "reason": err.Error()})
+			continue
+		}
+		if err != nil {
+			h.log.Errorf("reward event %s failed: %v", req.EventID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "create failed"})
+			return
+		}
+		if granted.Status == grantExisting {
+			c.JSON(http.StatusOK, gin.H{"rewarded": true, "reward_id": granted.ID, "status": granted.Status})
+			return
+		}
+		res := gin.H{
+			"rewarded":    true,
+			"reward_id":   granted.ID,
+			"status":      granted.Status,
+			"campaign_id": rule.CampaignID,
+			"rule_id":     rule.ID,
+			"symbol":      rule.Symbol,
+			"quantity":    rule.Quantity.StringFixed(6),
+		}
+		if granted.Status == grantPending {
+			c.JSON(http.StatusAccepted, res)
+			return
+		}
+		c.JSON(http.StatusCreated, res)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rewarded": false, "reason": "no campaign could fund the reward", "skipped": skipped})
+}
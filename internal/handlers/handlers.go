@@ -2,11 +2,20 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"stocky/internal/auth"
+	"stocky/internal/calendar"
 	"stocky/internal/database"
+	"stocky/internal/events"
+	"stocky/internal/policy"
 	"stocky/internal/service"
+	"stocky/internal/vesting"
 	"github.com/gin-gonic/gin"
 	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
@@ -16,19 +25,72 @@ type Handler struct {
 	repo     *database.Repo
 	priceSvc service.PriceProvider
 	log      *logrus.Logger
+	events   *events.Bus
+	calendar *calendar.Calendar
+	limits   policy.Config
+
+	approvalThreshold decimal.Decimal
+	rounding          string
+	sellFeeRate       decimal.Decimal
+}
+
+type Option func(*Handler)
+
+// WithEventBus makes the handler publish reward and portfolio events to b.
+func WithEventBus(b *events.Bus) Option {
+	return func(h *Handler) { h.events = b }
+}
+
+func WithCalendar(c *calendar.Calendar) Option {
+	return func(h *Handler) { h.calendar = c }
+}
+
+// WithRewardLimits enforces cfg on every reward granted through PostReward.
+func WithRewardLimits(cfg policy.Config) Option {
+	return func(h *Handler) { h.limits = cfg }
+}
+
+// WithApprovalThreshold holds rewards worth more than threshold INR for a
+// second admin's approval instead of crediting them immediately.
+func WithApprovalThreshold(threshold decimal.Decimal) Option {
+	return func(h *Handler) { h.approvalThreshold = threshold }
+}
+
+// WithRounding sets how rewards requested in INR are rounded to whole
+// micro-shares; see service.ParseRounding.
+func WithRounding(mode string) Option {
+	return func(h *Handler) { h.rounding = mode }
 }
 
-func NewHandler(r *database.Repo, p service.PriceProvider, log *logrus.Logger) *Handler {
-	return &Handler{repo: r, priceSvc: p, log: log}
+// WithSellFeeRate sets the fraction of the proceeds kept as fees when users
+// sell shares back; the default is service.DefaultSellFeeRate.
+func WithSellFeeRate(rate decimal.Decimal) Option {
+	return func(h *Handler) { h.sellFeeRate = rate }
+}
+
+func NewHandler(r *database.Repo, p service.PriceProvider, log *logrus.Logger, opts ...Option) *Handler {
+	h := &Handler{repo: r, priceSvc: p, log: log, rounding: service.RoundDown, sellFeeRate: service.DefaultSellFeeRate}
+	for _, o := range opts {
+		o(h)
+	}
+	return h
 }
 
 type RewardRequest struct {
 	IdempotencyKey string    `json:"idempotency_key"`
 	UserID         string    `json:"user_id" binding:"required"`
 	Symbol         string    `json:"symbol" binding:"required"`
-	Quantity       string    `json:"quantity" binding:"required"`
+	Quantity       string    `json:"quantity"`
 	Timestamp      time.Time `json:"timestamp" binding:"required"`
 	Source         string    `json:"source"`
+	// AmountINR grants shares worth this many rupees at the booking price
+	// instead of a fixed Quantity.
+	AmountINR string `json:"amount_inr"`
+	// Vesting, when set, releases the shares monthly after a cliff instead
+	// of crediting them at once.
+	Vesting *vesting.Schedule `json:"vesting"`
+	// CampaignID charges the reward to a campaign's budget.
+	CampaignID string `json:"campaign_id"`
 }
 
 func (h *Handler) PostReward(c *gin.Context) {
@@ -39,54 +101,288 @@ func (h *Handler) PostReward(c *gin.Context) {
 		return
 	}
 
-	// parse quantity
-	q, err := decimal.NewFromString(req.Quantity)
-	if err != nil {
-		h.log.Warnf("invalid quantity: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid quantity format"})
+	if (req.Quantity == "") == (req.AmountINR == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "exactly one of quantity or amount_inr is required"})
 		return
 	}
+	var q decimal.Decimal
+	var amount *decimal.Decimal
+	if req.AmountINR != "" {
+		a, err := decimal.NewFromString(req.AmountINR)
+		if err != nil || !a.IsPositive() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "amount_inr must be a positive amount"})
+			return
+		}
+		amount = &a
+	} else {
+		// parse quantity
+		var err error
+		q, err = decimal.NewFromString(req.Quantity)
+		if err != nil {
+			h.log.Warnf("invalid quantity: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid quantity format"})
+			return
+		}
+		if !q.IsPositive() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "quantity must be positive"})
+			return
+		}
+	}
+
+	if req.Vesting != nil {
+		if err := req.Vesting.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
-	ctx := context.Background()
+	ctx := h.auditContext(c)
+	var apiKeyID string
+	var dailyQuota int
+	if p, ok := auth.FromContext(c); ok && p.APIKey != nil {
+		if req.Source == "" {
+			req.Source = p.APIKey.Partner
+		}
+		if !p.APIKey.AllowsSource(req.Source) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "source not allowed for this api key"})
+			return
+		}
+		if !p.APIKey.AllowsSymbol(req.Symbol) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "symbol not allowed for this api key"})
+			return
+		}
+		// The quota is charged in the booking transaction, so rejected
+		// rewards and replays do not use it up.
+		apiKeyID, dailyQuota = p.APIKey.ID, p.APIKey.DailyQuota
+	}
 	if err := h.repo.EnsureUserExists(ctx, req.UserID, ""); err != nil {
 		h.log.Warnf("ensure user: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal"})
 		return
 	}
-	if err := h.repo.EnsureStockExists(ctx, req.Symbol, req.Symbol); err != nil {
-		h.log.Warnf("ensure stock: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal"})
-		return
-	}
 
 
-	price, _, err := h.priceSvc.GetPrice(ctx, req.Symbol)
-	if err != nil {
+	requestedBy := ""
+	if p, ok := auth.FromContext(c); ok {
+		requestedBy = p.Subject
+	}
+	res, err := h.grant(ctx, database.RewardInput{
+		UserID:         req.UserID,
+		Symbol:         req.Symbol,
+		Quantity:       q,
+		Timestamp:      req.Timestamp,
+		IdempotencyKey: req.IdempotencyKey,
+		Source:         req.Source,
+		RequestedBy:    requestedBy,
+		Vesting:        req.Vesting,
+		CampaignID:     req.CampaignID,
+		AmountINR:      amount,
+		APIKeyID:       apiKeyID,
+		DailyQuota:     dailyQuota,
+	})
+	var violation *policy.Violation
+	switch {
+	case errors.Is(err, database.ErrQuotaExceeded):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errAmountTooSmall), errors.Is(err, errLotPrecision):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errUnknownStock), errors.Is(err, errInactiveStock):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errPriceUnavailable):
 		h.log.Warnf("price fetch failed: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "price fetch failed"})
 		return
-	}
-
-	id, created, err := h.repo.CreateReward(ctx, req.UserID, req.Symbol, q, req.Timestamp, req.IdempotencyKey, req.Source, price)
-	if err != nil {
+	case errors.As(err, &violation):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "reward limit exceeded", "violation": violation})
+		return
+	case errors.Is(err, database.ErrCampaignUnavailable), errors.Is(err, database.ErrCampaignBudget), errors.Is(err, database.ErrCampaignUserCap):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "campaign not found"})
+		return
+	case err != nil:
 		h.log.Errorf("create reward failed: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "create failed"})
 		return
 	}
+	switch res.Status {
+	case grantExisting:
+		c.JSON(http.StatusOK, gin.H{"reward_id": res.ID, "status": "already_exists"})
+	case grantPending:
+		c.JSON(http.StatusAccepted, gin.H{"reward_id": res.ID, "status": "pending_approval", "quantity": res.Quantity.StringFixed(6)})
+	default:
+		c.JSON(http.StatusCreated, gin.H{"reward_id": res.ID, "quantity": res.Quantity.StringFixed(6), "price_inr": res.Price.StringFixed(4)})
+	}
+}
+
+const (
+	grantCreated  = "created"
+	grantExisting = "already_exists"
+	grantPending  = "pending_approval"
+)
+
+var (
+	errPriceUnavailable = errors.New("price unavailable")
+	errAmountTooSmall   = errors.New("amount_inr is too small to buy any shares")
+	errUnknownStock     = errors.New("unknown symbol")
+	errInactiveStock    = errors.New("symbol is delisted")
+	errLotPrecision     = errors.New("quantity has more decimal places than the stock allows")
+)
+
+// tradableStock returns the stock master entry for symbol if rewards may be
+// granted in it.
+func (h *Handler) tradableStock(ctx context.Context, symbol string) (database.Stock, error) {
+	s, err := h.repo.GetStock(ctx, symbol)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, fmt.Errorf("%w: %s", errUnknownStock, symbol)
+	}
+	if err != nil {
+		return s, err
+	}
+	if !s.Active {
+		return s, fmt.Errorf("%w: %s", errInactiveStock, symbol)
+	}
+	return s, nil
+}
+
+// checkLotPrecision rejects quantities finer than the stock's lot precision.
+func checkLotPrecision(s database.Stock, q decimal.Decimal) error {
+	if !q.Equal(q.Truncate(s.LotPrecision)) {
+		return fmt.Errorf("%w: %s allows %d", errLotPrecision, s.Symbol, s.LotPrecision)
+	}
+	return nil
+}
+
+// checkHeldQuantity checks a quantity of held shares being moved, sold or
+// withdrawn against its stock's lot precision. Delisted stocks are allowed
+// since their shares are still held. It writes the error response and
+// returns false if q is not acceptable.
+func (h *Handler) checkHeldQuantity(c *gin.Context, symbol string, q decimal.Decimal) bool {
+	s, err := h.repo.GetStock(context.Background(), symbol)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Errorf("%w: %s", errUnknownStock, symbol).Error()})
+		return false
+	}
+	if err != nil {
+		h.log.Errorf("get stock failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return false
+	}
+	if err := checkLotPrecision(s, q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+type grantResult struct {
+	ID       string
+	Status   string
+	Quantity decimal.Decimal
+	Price    decimal.Decimal
+}
+
+// grant books a reward in an active stock at the provider's current price
+// under the configured limits, holding it for approval above the threshold,
+// and publishes it once credited. in.Price, in.Limits and in.RequiresApproval
+// are filled in here, and in.Quantity too, at the stock's lot precision,
+// when the reward is requested as in.AmountINR.
+func (h *Handler) grant(ctx context.Context, in database.RewardInput) (grantResult, error) {
+	stock, err := h.tradableStock(ctx, in.Symbol)
+	if err != nil {
+		return grantResult{}, err
+	}
+	if in.AmountINR == nil {
+		if err := checkLotPrecision(stock, in.Quantity); err != nil {
+			return grantResult{}, err
+		}
+	}
+	price, _, err := h.priceSvc.GetPrice(ctx, in.Symbol)
+	if err != nil {
+		return grantResult{}, fmt.Errorf("%w: %v", errPriceUnavailable, err)
+	}
+	if in.AmountINR != nil {
+		q, _, err := service.QuantityForAmountAt(*in.AmountINR, price, h.rounding, stock.LotPrecision)
+		if err != nil {
+			return grantResult{}, fmt.Errorf("%w: %v", errPriceUnavailable, err)
+		}
+		if !q.IsPositive() {
+			return grantResult{}, errAmountTooSmall
+		}
+		in.Quantity = q
+	}
+	limits := h.limits.For(in.Symbol)
+	in.Price = price
+	in.Limits = &limits
+	in.RequiresApproval = h.approvalThreshold.IsPositive() && in.Quantity.Mul(price).GreaterThan(h.approvalThreshold)
+
+	id, created, err := h.repo.CreateReward(ctx, in)
+	if err != nil {
+		return grantResult{}, err
+	}
+	res := grantResult{ID: id, Status: grantCreated, Quantity: in.Quantity, Price: price}
 	if !created {
-		c.JSON(http.StatusOK, gin.H{"reward_id": id, "status": "already_exists"})
-		return
+		res.Status = grantExisting
+		return res, nil
+	}
+	if in.RequiresApproval {
+		res.Status = grantPending
+		return res, nil
 	}
-	c.JSON(http.StatusCreated, gin.H{"reward_id": id})
+	h.publish(events.Event{Type: events.RewardCreated, UserID: in.UserID, Data: gin.H{
+		"reward_id": id,
+		"symbol":    in.Symbol,
+		"quantity":  in.Quantity.StringFixed(6),
+		"price_inr": price.StringFixed(4),
+		"timestamp": in.Timestamp,
+	}})
+	h.publishPortfolio(ctx, in.UserID)
+	return res, nil
+}
+
+// RevertRequest says why a reward is being reversed. Reason is one of
+// database.ReversalReasons.
+type RevertRequest struct {
+	Reason string `json:"reason" binding:"required"`
+	Note   string `json:"note" binding:"required"`
 }
 
 func (h *Handler) RevertReward(c *gin.Context) {
+	var req RevertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !database.ValidReversalReason(req.Reason) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason must be one of " + strings.Join(database.ReversalReasons, ", ")})
+		return
+	}
+	p, _ := auth.FromContext(c)
 	id := c.Param("id")
-	if err := h.repo.ReverseReward(context.Background(), id); err != nil {
+	ctx := h.auditContext(c)
+	err := h.repo.ReverseReward(ctx, id, database.Reversal{Reason: req.Reason, Note: req.Note, ReversedBy: p.Subject})
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "completed reward not found"})
+		return
+	case errors.Is(err, database.ErrSharesNotHeld):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
 		h.log.Errorf("revert reward failed: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "revert failed"})
 		return
 	}
+	if userID, err := h.repo.GetRewardOwner(ctx, id); err == nil {
+		h.publish(events.Event{Type: events.RewardReversed, UserID: userID, Data: gin.H{"reward_id": id}})
+		h.publishPortfolio(ctx, userID)
+	} else {
+		h.log.Warnf("lookup reward owner for %s: %v", id, err)
+	}
 	c.JSON(http.StatusOK, gin.H{"status": "reversed"})
 }
 
@@ -109,7 +405,20 @@ func (h *Handler) GetPortfolio(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"items": items, "total_inr": total.StringFixed(4)})
+	unvested, invested := decimal.Zero, decimal.Zero
+	for _, it := range items {
+		unvested = unvested.Add(it.UnvestedValue)
+		invested = invested.Add(it.InvestedINR)
+	}
+	gain := total.Sub(invested)
+	c.JSON(http.StatusOK, gin.H{
+		"items":          items,
+		"total_inr":      total.StringFixed(4),
+		"unvested_inr":   unvested.StringFixed(4),
+		"invested_inr":   invested.StringFixed(4),
+		"unrealised_inr": gain.StringFixed(4),
+		"unrealised_pct": database.GainPct(gain, invested).StringFixed(2),
+	})
 }
 
 func (h *Handler) GetStats(c *gin.Context) {
@@ -142,17 +451,52 @@ func (h *Handler) GetStats(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"shares_today": sharesTodayStr, "current_inr_value": total.StringFixed(4)})
 }
 
+// GetHistoricalINR returns the user's end-of-day values. Optional query
+// parameters: from and to (YYYY-MM-DD), granularity (daily, weekly or
+// monthly), include_today and by_symbol.
 func (h *Handler) GetHistoricalINR(c *gin.Context) {
 	userId := c.Param("userId")
-	rows, err := h.repo.GetDailyValuations(context.Background(), userId)
+	var q database.ValuationQuery
+	var ok bool
+	if q.From, ok = parseDateQuery(c, "from"); !ok {
+		return
+	}
+	if q.To, ok = parseDateQuery(c, "to"); !ok {
+		return
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && q.To.Before(q.From) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+		return
+	}
+	switch q.Granularity = c.DefaultQuery("granularity", database.GranularityDaily); q.Granularity {
+	case database.GranularityDaily, database.GranularityWeekly, database.GranularityMonthly:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "granularity must be daily, weekly or monthly"})
+		return
+	}
+	q.IncludeToday = c.Query("include_today") == "true"
+	q.BySymbol = c.Query("by_symbol") == "true"
+
+	rows, err := h.repo.GetDailyValuations(context.Background(), userId, q)
 	if err != nil {
 		h.log.Errorf("get daily valuations failed: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
 		return
 	}
-	res := []map[string]string{}
+	res := []gin.H{}
 	for _, r := range rows {
-		res = append(res, map[string]string{"date": r.Date, "inr_value": r.TotalINR.StringFixed(4)})
+		point := gin.H{"date": r.Date, "inr_value": r.TotalINR.StringFixed(4)}
+		if r.Symbols != nil {
+			symbols := map[string]string{}
+			for sym, v := range r.Symbols {
+				symbols[sym] = v.StringFixed(4)
+			}
+			point["symbols"] = symbols
+		}
+		if r.Intraday {
+			point["intraday"] = true
+		}
+		res = append(res, point)
 	}
 	c.JSON(http.StatusOK, res)
 }
\ No newline at end of file
@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) GetMarketStatus(c *gin.Context) {
+	if h.calendar == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "market calendar not configured"})
+		return
+	}
+	c.JSON(http.StatusOK, h.calendar.Status(time.Now()))
+}
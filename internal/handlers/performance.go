@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"stocky/internal/database"
+	"stocky/internal/performance"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseDateQuery reads an optional YYYY-MM-DD query parameter.
+func parseDateQuery(c *gin.Context, key string) (time.Time, bool) {
+	v := c.Query(key)
+	if v == "" {
+		return time.Time{}, true
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + key + ", want YYYY-MM-DD"})
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// GetPerformance reports time- and money-weighted returns, maximum drawdown
+// and the best and worst day between ?from= and ?to= (default: all history),
+// from the same daily values as /historical-inr.
+func (h *Handler) GetPerformance(c *gin.Context) {
+	from, ok := parseDateQuery(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := parseDateQuery(c, "to")
+	if !ok {
+		return
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+		return
+	}
+
+	userID := c.Param("userId")
+	ctx := context.Background()
+	loc, err := h.repo.UserLocation(ctx, userID)
+	if err != nil {
+		h.log.Errorf("get user timezone failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	rows, err := h.repo.GetDailyValuations(ctx, userID, database.ValuationQuery{Location: loc})
+	if err != nil {
+		h.log.Errorf("get daily valuations failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	flows, err := h.repo.CashFlows(ctx, userID)
+	if err != nil {
+		h.log.Errorf("get cash flows failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	points := make([]performance.Point, 0, len(rows))
+	for _, r := range rows {
+		d, err := time.Parse("2006-01-02", r.Date)
+		if err != nil {
+			h.log.Warnf("skip valuation with bad date %q", r.Date)
+			continue
+		}
+		points = append(points, performance.Point{Date: d, Value: r.TotalINR})
+	}
+
+	res := performance.Compute(performance.Window(points, from, to), flows, loc)
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "performance": res})
+}
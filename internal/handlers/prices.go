@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+func (h *Handler) GetQuarantinedPrices(c *gin.Context) {
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 1000 {
+			limit = n
+		}
+	}
+	rows, err := h.repo.ListQuarantinedPrices(context.Background(), c.Query("symbol"), limit)
+	if err != nil {
+		h.log.Errorf("list quarantined prices failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, rows)
+}
+
+type PriceOverrideRequest struct {
+	PriceINR string `json:"price_inr" binding:"required"`
+	Reason   string `json:"reason" binding:"required"`
+}
+
+// OverridePrice sets a symbol's current price by hand, for example while
+// the feed is wrong. The provider replaces it at its next tick.
+func (h *Handler) OverridePrice(c *gin.Context) {
+	var req PriceOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	price, err := decimal.NewFromString(req.PriceINR)
+	if err != nil || !price.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "price_inr must be a positive amount"})
+		return
+	}
+	symbol := c.Param("symbol")
+	ts := time.Now().UTC()
+	err = h.repo.OverridePrice(h.auditContext(c), symbol, price, ts, req.Reason)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "stock not found"})
+		return
+	}
+	if err != nil {
+		h.log.Errorf("override price failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"symbol": symbol, "price_inr": price.StringFixed(4), "timestamp": ts})
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"stocky/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// Statuses each reward listing may filter on.
+var (
+	userRewardStatuses  = []string{database.StatusCompleted, database.StatusReversed}
+	adminRewardStatuses = []string{database.StatusCompleted, database.StatusReversed, database.StatusPendingApproval, database.StatusRejected}
+)
+
+// rewardFilter reads the filters shared by the reward listings: symbol,
+// status (one of statuses), source, reversal_reason, from/to dates in loc
+// (to is inclusive), cursor and limit.
+func rewardFilter(c *gin.Context, loc *time.Location, statuses []string) (database.RewardFilter, bool) {
+	f := database.RewardFilter{Symbol: c.Query("symbol"), Status: c.Query("status"), Source: c.Query("source"), ReversalReason: c.Query("reversal_reason"), Limit: 100}
+	if f.ReversalReason != "" && !database.ValidReversalReason(f.ReversalReason) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reversal_reason must be one of " + strings.Join(database.ReversalReasons, ", ")})
+		return f, false
+	}
+	if f.Status != "" {
+		known := false
+		for _, s := range statuses {
+			known = known || s == f.Status
+		}
+		if !known {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of " + strings.Join(statuses, ", ")})
+			return f, false
+		}
+	}
+	from, ok := parseDateQuery(c, "from")
+	if !ok {
+		return f, false
+	}
+	to, ok := parseDateQuery(c, "to")
+	if !ok {
+		return f, false
+	}
+	if !from.IsZero() {
+		f.From = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	}
+	if !to.IsZero() {
+		f.To = time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, loc)
+	}
+	if v := c.Query("cursor"); v != "" {
+		cur, err := database.DecodeRewardCursor(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return f, false
+		}
+		f.After = &cur
+	}
+	if v := c.Query("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 1000 {
+			f.Limit = n
+		}
+	}
+	return f, true
+}
+
+// GetRewards pages through a user's reward history, newest first. Pass the
+// returned next_cursor as ?cursor= to fetch the following page.
+func (h *Handler) GetRewards(c *gin.Context) {
+	userID := c.Param("userId")
+	ctx := context.Background()
+	loc, err := h.repo.UserLocation(ctx, userID)
+	if err != nil {
+		h.log.Errorf("get user timezone failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	f, ok := rewardFilter(c, loc, userRewardStatuses)
+	if !ok {
+		return
+	}
+	f.UserID = userID
+	rows, next, err := h.repo.ListRewards(ctx, f)
+	if err != nil {
+		h.log.Errorf("list rewards failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	res := gin.H{"rewards": rows, "next_cursor": nil}
+	if next != nil {
+		res["next_cursor"] = next.Encode()
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// SearchRewards lets support find rewards across users by user_id,
+// idempotency_key, source, symbol, status and from/to dates in the server's
+// timezone. Pending and rejected rewards are included unless status says
+// otherwise.
+func (h *Handler) SearchRewards(c *gin.Context) {
+	f, ok := rewardFilter(c, h.repo.Location(), adminRewardStatuses)
+	if !ok {
+		return
+	}
+	f.UserID = c.Query("user_id")
+	f.IdempotencyKey = c.Query("idempotency_key")
+	f.IncludePending = true
+	rows, next, err := h.repo.ListRewards(context.Background(), f)
+	if err != nil {
+		h.log.Errorf("search rewards failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	res := gin.H{"rewards": rows, "next_cursor": nil}
+	if next != nil {
+		res["next_cursor"] = next.Encode()
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// GetRewardDetail returns a reward with its ledger entries, its effect on
+// the user's holdings, its approval and its lifecycle events.
+func (h *Handler) GetRewardDetail(c *gin.Context) {
+	d, err := h.repo.GetRewardDetail(context.Background(), c.Param("id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "reward not found"})
+		return
+	}
+	if err != nil {
+		h.log.Errorf("get reward detail failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, d)
+}
+
+// GetReversalReport totals reversed rewards by reason code, optionally for
+// one source and for reversals between from and to (inclusive dates in the
+// server timezone).
+func (h *Handler) GetReversalReport(c *gin.Context) {
+	loc := h.repo.Location()
+	from, ok := parseDateQuery(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := parseDateQuery(c, "to")
+	if !ok {
+		return
+	}
+	if !from.IsZero() {
+		from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	}
+	if !to.IsZero() {
+		to = time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, loc)
+	}
+	rows, err := h.repo.ReversalReport(context.Background(), from, to, c.Query("source"))
+	if err != nil {
+		h.log.Errorf("reversal report failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	total := decimal.Zero
+	count := 0
+	for _, r := range rows {
+		total = total.Add(r.ValueINR)
+		count += r.Count
+	}
+	c.JSON(http.StatusOK, gin.H{"reasons": rows, "count": count, "value_inr": total.StringFixed(4)})
+}
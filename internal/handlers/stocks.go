@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"stocky/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) stockError(c *gin.Context, err error, what string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "stock not found"})
+	case errors.Is(err, database.ErrInvalidStock):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrStockExists), errors.Is(err, database.ErrISINInUse), errors.Is(err, database.ErrFractionalHoldings):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.log.Errorf("%s stock failed: %v", what, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": what + " failed"})
+	}
+}
+
+// ListStocks returns the stock master, active stocks only unless
+// include_delisted=true.
+func (h *Handler) ListStocks(c *gin.Context) {
+	rows, err := h.repo.ListStocks(context.Background(), c.Query("include_delisted") == "true")
+	if err != nil {
+		h.log.Errorf("list stocks failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, rows)
+}
+
+func (h *Handler) GetStock(c *gin.Context) {
+	s, err := h.repo.GetStock(context.Background(), c.Param("symbol"))
+	if err != nil {
+		h.stockError(c, err, "query")
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+func (h *Handler) CreateStock(c *gin.Context) {
+	var req database.StockSpec
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	s, err := h.repo.CreateStock(h.auditContext(c), req.Stock())
+	if err != nil {
+		h.stockError(c, err, "create")
+		return
+	}
+	c.JSON(http.StatusCreated, s)
+}
+
+// UpdateStock changes the fields present in the body; the symbol itself
+// cannot change because rewards, holdings and prices refer to it.
+func (h *Handler) UpdateStock(c *gin.Context) {
+	var req database.StockUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	s, err := h.repo.UpdateStock(h.auditContext(c), c.Param("symbol"), req)
+	if err != nil {
+		h.stockError(c, err, "update")
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+// DelistStock marks a stock delisted. Its history stays, so this is the
+// only way to remove a stock.
+func (h *Handler) DelistStock(c *gin.Context) {
+	s, err := h.repo.DelistStock(h.auditContext(c), c.Param("symbol"))
+	if err != nil {
+		h.stockError(c, err, "update")
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"stocky/internal/events"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	streamWriteWait  = 10 * time.Second
+	streamPongWait   = 60 * time.Second
+	streamPingPeriod = 50 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// Stream upgrades the connection to a WebSocket and pushes the user's
+// reward and portfolio events until the client disconnects. Callers are
+// authenticated by the auth middleware before the upgrade.
+func (h *Handler) Stream(c *gin.Context) {
+	userID := c.Param("userId")
+	if h.events == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "event stream disabled"})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		h.log.Warnf("websocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	sub := h.events.Subscribe(userID)
+	defer sub.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.SetReadDeadline(time.Now().Add(streamPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(streamPongWait))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if ev, ok := h.portfolioEvent(context.Background(), userID); ok {
+		conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+		if err := conn.WriteJSON(ev); err != nil {
+			return
+		}
+	}
+
+	ping := time.NewTicker(streamPingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case ev, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if err := conn.WriteJSON(ev); err != nil {
+				h.log.Debugf("stream write for %s failed: %v", userID, err)
+				return
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (h *Handler) publish(ev events.Event) {
+	if h.events == nil {
+		return
+	}
+	h.events.Publish(ev)
+}
+
+func (h *Handler) portfolioEvent(ctx context.Context, userID string) (events.Event, bool) {
+	items, total, err := h.repo.GetPortfolio(ctx, userID)
+	if err != nil {
+		h.log.Warnf("portfolio valuation for %s failed: %v", userID, err)
+		return events.Event{}, false
+	}
+	return events.Event{
+		Type:   events.PortfolioValued,
+		UserID: userID,
+		Time:   time.Now().UTC(),
+		Data:   gin.H{"items": items, "total_inr": total.StringFixed(4)},
+	}, true
+}
+
+func (h *Handler) publishPortfolio(ctx context.Context, userID string) {
+	if h.events == nil {
+		return
+	}
+	if ev, ok := h.portfolioEvent(ctx, userID); ok {
+		h.events.Publish(ev)
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"time"
+
+	"stocky/internal/tax"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCapitalGains reports a user's short- and long-term capital gains on
+// shares sold back in a financial year (?fy=2025-26, default the current
+// one), as JSON or, with ?format=csv, as a CSV download.
+func (h *Handler) GetCapitalGains(c *gin.Context) {
+	fy := tax.FYOf(time.Now())
+	if v := c.Query("fy"); v != "" {
+		var err error
+		if fy, err = tax.ParseFY(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+		return
+	}
+
+	userID := c.Param("userId")
+	disposals, err := h.repo.ListDisposals(context.Background(), userID, fy.Start, fy.End)
+	if err != nil {
+		h.log.Errorf("list disposals failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	rep := tax.Build(fy, disposals)
+	if format == "json" {
+		c.JSON(http.StatusOK, gin.H{
+			"user_id":      userID,
+			"fy":           rep.FY,
+			"stcg_inr":     rep.STCGINR.StringFixed(4),
+			"ltcg_inr":     rep.LTCGINR.StringFixed(4),
+			"total_inr":    rep.TotalINR.StringFixed(4),
+			"proceeds_inr": rep.ProceedsINR.StringFixed(4),
+			"cost_inr":     rep.CostINR.StringFixed(4),
+			"gains":        rep.Gains,
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="capital-gains-%s-%s.csv"`, userID, rep.FY))
+	c.Header("Content-Type", "text/csv")
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write(tax.CSVHeader)
+	for _, g := range rep.Gains {
+		_ = w.Write(g.CSVRow())
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		h.log.Warnf("write capital gains csv: %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"stocky/internal/calendar"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TimezoneRequest struct {
+	// Timezone is an IANA zone such as "Europe/London"; empty restores the
+	// server default.
+	Timezone string `json:"timezone"`
+}
+
+// SetTimezone changes the zone whose midnight starts the user's days in
+// /today-stocks, /stats, /historical-inr and /performance.
+func (h *Handler) SetTimezone(c *gin.Context) {
+	var req TimezoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Timezone != "" {
+		if _, err := calendar.LoadLocation(req.Timezone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown timezone " + req.Timezone})
+			return
+		}
+	}
+	userID := c.Param("userId")
+	ctx := context.Background()
+	err := h.repo.SetUserTimezone(ctx, userID, req.Timezone)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		h.log.Errorf("set timezone failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	loc, err := h.repo.UserLocation(ctx, userID)
+	if err != nil {
+		h.log.Errorf("get user timezone failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "timezone": loc.String()})
+}
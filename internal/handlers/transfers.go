@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"stocky/internal/auth"
+	"stocky/internal/database"
+	"stocky/internal/events"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+type TransferRequest struct {
+	IdempotencyKey string `json:"idempotency_key"`
+	FromUserID     string `json:"from_user_id" binding:"required"`
+	ToUserID       string `json:"to_user_id" binding:"required"`
+	Symbol         string `json:"symbol" binding:"required"`
+	Quantity       string `json:"quantity" binding:"required"`
+	Kind           string `json:"kind"`
+	Note           string `json:"note"`
+}
+
+// PostTransfer moves shares between users. Users may only gift their own
+// shares; admin and service principals may move any holdings, for example
+// to merge duplicate accounts.
+func (h *Handler) PostTransfer(c *gin.Context) {
+	var req TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q, err := decimal.NewFromString(req.Quantity)
+	if err != nil || !q.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quantity must be a positive number"})
+		return
+	}
+	if req.FromUserID == req.ToUserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot transfer to the same user"})
+		return
+	}
+	if req.Kind == "" {
+		req.Kind = database.TransferGift
+	}
+	if req.Kind != database.TransferGift && req.Kind != database.TransferMerge {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be gift or merge"})
+		return
+	}
+
+	p, _ := auth.FromContext(c)
+	if p.Role == auth.RoleUser && (p.Subject != req.FromUserID || req.Kind != database.TransferGift) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	ctx := context.Background()
+	ok, err := h.repo.UserExists(ctx, req.ToUserID)
+	if err != nil {
+		h.log.Errorf("lookup user failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "recipient not found"})
+		return
+	}
+	if !h.checkHeldQuantity(c, req.Symbol, q) {
+		return
+	}
+	price, _, err := h.priceSvc.GetPrice(ctx, req.Symbol)
+	if err != nil {
+		h.log.Warnf("price fetch failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "price fetch failed"})
+		return
+	}
+
+	t := database.Transfer{
+		FromUserID:  req.FromUserID,
+		ToUserID:    req.ToUserID,
+		Symbol:      req.Symbol,
+		Quantity:    q,
+		PriceINR:    price,
+		Kind:        req.Kind,
+		InitiatedBy: p.Subject,
+	}
+	if req.Note != "" {
+		t.Note = &req.Note
+	}
+	if req.IdempotencyKey != "" {
+		t.IdempotencyKey = &req.IdempotencyKey
+	}
+	res, created, err := h.repo.CreateTransfer(ctx, t)
+	if errors.Is(err, database.ErrInsufficientHoldings) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.log.Errorf("create transfer failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "transfer failed"})
+		return
+	}
+	if !created {
+		c.JSON(http.StatusOK, gin.H{"transfer": res, "status": "already_exists"})
+		return
+	}
+	for _, userID := range []string{res.FromUserID, res.ToUserID} {
+		h.publish(events.Event{Type: events.TransferDone, UserID: userID, Data: res})
+		h.publishPortfolio(ctx, userID)
+	}
+	c.JSON(http.StatusCreated, gin.H{"transfer": res})
+}
+
+func (h *Handler) GetTransfers(c *gin.Context) {
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 1000 {
+			limit = n
+		}
+	}
+	rows, err := h.repo.ListTransfers(context.Background(), c.Param("userId"), limit)
+	if err != nil {
+		h.log.Errorf("list transfers failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, rows)
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+
+	"stocky/internal/events"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChurnUser lapses every unvested grant of a user who has left the
+// programme and rejects vesting grants still awaiting approval. Shares that
+// already vested stay in their holdings.
+func (h *Handler) ChurnUser(c *gin.Context) {
+	userID := c.Param("userId")
+	ctx := h.auditContext(c)
+	lapsed, rejected, err := h.repo.LapseUnvested(ctx, userID)
+	if err != nil {
+		h.log.Errorf("lapse unvested for %s failed: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "lapse failed"})
+		return
+	}
+	for _, rel := range lapsed {
+		h.publish(events.Event{Type: events.RewardLapsed, UserID: userID, Data: rel})
+	}
+	if len(lapsed) > 0 {
+		h.publishPortfolio(ctx, userID)
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "lapsed": lapsed, "rejected": rejected})
+}
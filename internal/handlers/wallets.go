@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"stocky/internal/auth"
+	"stocky/internal/database"
+	"stocky/internal/events"
+	"stocky/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+type SellRequest struct {
+	IdempotencyKey string `json:"idempotency_key"`
+	UserID         string `json:"user_id" binding:"required"`
+	Symbol         string `json:"symbol" binding:"required"`
+	Quantity       string `json:"quantity" binding:"required"`
+}
+
+// PostSell sells a user's shares back at the current price and credits the
+// proceeds, less fees, to their wallet. Users may only sell their own shares.
+func (h *Handler) PostSell(c *gin.Context) {
+	var req SellRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q, err := decimal.NewFromString(req.Quantity)
+	if err != nil || !q.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quantity must be a positive number"})
+		return
+	}
+	p, _ := auth.FromContext(c)
+	if p.Role == auth.RoleUser && p.Subject != req.UserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	if !h.checkHeldQuantity(c, req.Symbol, q) {
+		return
+	}
+
+	ctx := context.Background()
+	price, _, err := h.priceSvc.GetPrice(ctx, req.Symbol)
+	if err != nil {
+		h.log.Warnf("price fetch failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "price fetch failed"})
+		return
+	}
+	gross, fees, net := service.SaleProceeds(q, price, h.sellFeeRate)
+	s := database.Sale{
+		UserID:      req.UserID,
+		Symbol:      req.Symbol,
+		Quantity:    q,
+		PriceINR:    price,
+		GrossINR:    gross,
+		FeesINR:     fees,
+		NetINR:      net,
+		InitiatedBy: p.Subject,
+	}
+	if req.IdempotencyKey != "" {
+		s.IdempotencyKey = &req.IdempotencyKey
+	}
+	res, created, err := h.repo.CreateSale(ctx, s)
+	if errors.Is(err, database.ErrInsufficientHoldings) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.log.Errorf("create sale failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "sale failed"})
+		return
+	}
+	if !created {
+		c.JSON(http.StatusOK, gin.H{"sale": res, "status": "already_exists"})
+		return
+	}
+	h.publish(events.Event{Type: events.SaleDone, UserID: res.UserID, Data: res})
+	h.publishPortfolio(ctx, res.UserID)
+	c.JSON(http.StatusCreated, gin.H{"sale": res})
+}
+
+func (h *Handler) GetWallet(c *gin.Context) {
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 1000 {
+			limit = n
+		}
+	}
+	userID := c.Param("userId")
+	balance, history, err := h.repo.GetWallet(context.Background(), userID, limit)
+	if err != nil {
+		h.log.Errorf("get wallet failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "balance_inr": balance.StringFixed(4), "transactions": history})
+}
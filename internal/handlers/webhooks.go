@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"stocky/internal/events"
+
+	"github.com/gin-gonic/gin"
+)
+
+var webhookEventTypes = map[string]bool{
+	events.RewardCreated:   true,
+	events.RewardReversed:  true,
+	events.RewardVested:    true,
+	events.RewardLapsed:    true,
+	events.TransferDone:    true,
+	events.WithdrawalState: true,
+	events.SaleDone:        true,
+}
+
+type WebhookRequest struct {
+	URL        string   `json:"url" binding:"required"`
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"event_types" binding:"required"`
+}
+
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an absolute http(s) url"})
+		return
+	}
+	if len(req.EventTypes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "event_types must not be empty"})
+		return
+	}
+	for _, t := range req.EventTypes {
+		if !webhookEventTypes[t] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported event type " + t})
+			return
+		}
+	}
+	if req.Secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			h.log.Errorf("generate webhook secret: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal"})
+			return
+		}
+		req.Secret = hex.EncodeToString(b)
+	}
+
+	sub, err := h.repo.CreateWebhookSubscription(context.Background(), req.URL, req.Secret, req.EventTypes)
+	if err != nil {
+		h.log.Errorf("create webhook failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create failed"})
+		return
+	}
+	// The secret is only ever returned once, at creation.
+	c.JSON(http.StatusCreated, gin.H{"subscription": sub, "secret": sub.Secret})
+}
+
+func (h *Handler) ListWebhooks(c *gin.Context) {
+	subs, err := h.repo.ListWebhookSubscriptions(context.Background())
+	if err != nil {
+		h.log.Errorf("list webhooks failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, subs)
+}
+
+func (h *Handler) GetWebhookDeliveries(c *gin.Context) {
+	limit := 50
+	if v := c.Query("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 500 {
+			limit = n
+		}
+	}
+	rows, err := h.repo.ListWebhookDeliveries(context.Background(), c.Param("id"), limit)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+		return
+	}
+	if err != nil {
+		h.log.Errorf("list webhook deliveries failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, rows)
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"stocky/internal/auth"
+	"stocky/internal/database"
+	"stocky/internal/events"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+type WithdrawalRequest struct {
+	IdempotencyKey string `json:"idempotency_key"`
+	Symbol         string `json:"symbol" binding:"required"`
+	Quantity       string `json:"quantity" binding:"required"`
+	DematAccount   string `json:"demat_account" binding:"required"`
+}
+
+func (h *Handler) RequestWithdrawal(c *gin.Context) {
+	var req WithdrawalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q, err := decimal.NewFromString(req.Quantity)
+	if err != nil || !q.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quantity must be a positive number"})
+		return
+	}
+	if !h.checkHeldQuantity(c, req.Symbol, q) {
+		return
+	}
+	w := database.Withdrawal{
+		UserID:       c.Param("userId"),
+		Symbol:       req.Symbol,
+		Quantity:     q,
+		DematAccount: req.DematAccount,
+	}
+	if req.IdempotencyKey != "" {
+		w.IdempotencyKey = &req.IdempotencyKey
+	}
+	p, _ := auth.FromContext(c)
+	ctx := context.Background()
+	res, created, err := h.repo.RequestWithdrawal(ctx, w, p.Subject)
+	if errors.Is(err, database.ErrInsufficientHoldings) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.log.Errorf("request withdrawal failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create failed"})
+		return
+	}
+	if !created {
+		c.JSON(http.StatusOK, gin.H{"withdrawal": res, "status": "already_exists"})
+		return
+	}
+	h.publishWithdrawal(ctx, res)
+	c.JSON(http.StatusCreated, gin.H{"withdrawal": res})
+}
+
+func (h *Handler) publishWithdrawal(ctx context.Context, w database.Withdrawal) {
+	h.publish(events.Event{Type: events.WithdrawalState, UserID: w.UserID, Data: w})
+	h.publishPortfolio(ctx, w.UserID)
+}
+
+func withdrawalLimit(c *gin.Context) int {
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 1000 {
+			limit = n
+		}
+	}
+	return limit
+}
+
+func (h *Handler) GetUserWithdrawals(c *gin.Context) {
+	rows, err := h.repo.ListWithdrawals(context.Background(), c.Param("userId"), c.Query("status"), withdrawalLimit(c))
+	if err != nil {
+		h.log.Errorf("list withdrawals failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, rows)
+}
+
+func (h *Handler) ListWithdrawals(c *gin.Context) {
+	rows, err := h.repo.ListWithdrawals(context.Background(), c.Query("user_id"), c.Query("status"), withdrawalLimit(c))
+	if err != nil {
+		h.log.Errorf("list withdrawals failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, rows)
+}
+
+func (h *Handler) GetWithdrawal(c *gin.Context) {
+	w, history, err := h.repo.GetWithdrawal(context.Background(), c.Param("id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "withdrawal not found"})
+		return
+	}
+	if err != nil {
+		h.log.Errorf("get withdrawal failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"withdrawal": w, "history": history})
+}
+
+type WithdrawalUpdateRequest struct {
+	Note        string `json:"note"`
+	ExternalRef string `json:"external_ref"`
+}
+
+// advanceWithdrawal moves a withdrawal to status on an operator's behalf.
+func (h *Handler) advanceWithdrawal(c *gin.Context, status string) {
+	var req WithdrawalUpdateRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	p, _ := auth.FromContext(c)
+	ctx := context.Background()
+	w, err := h.repo.AdvanceWithdrawal(ctx, c.Param("id"), status, p.Subject, req.Note, req.ExternalRef)
+	var terr *database.TransitionError
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "withdrawal not found"})
+		return
+	case errors.As(err, &terr):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		h.log.Errorf("update withdrawal failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	h.publishWithdrawal(ctx, w)
+	c.JSON(http.StatusOK, w)
+}
+
+func (h *Handler) ProcessWithdrawal(c *gin.Context) {
+	h.advanceWithdrawal(c, database.WithdrawalProcessing)
+}
+
+func (h *Handler) SettleWithdrawal(c *gin.Context) {
+	h.advanceWithdrawal(c, database.WithdrawalSettled)
+}
+
+func (h *Handler) FailWithdrawal(c *gin.Context) {
+	h.advanceWithdrawal(c, database.WithdrawalFailed)
+}
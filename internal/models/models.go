@@ -1,11 +1,31 @@
 package models
 
-import "time"
+import (
+	"time"
 
+	"github.com/shopspring/decimal"
+)
+
+// Reward is the API representation of a booked reward. PriceINR and
+// ValueINR are null for rewards booked before prices were recorded.
 type Reward struct {
-	ID        string    `db:"id" json:"reward_id"`
-	UserID    string    `db:"user_id" json:"user_id"`
-	Symbol    string    `db:"symbol" json:"symbol"`
-	Quantity  string    `db:"quantity" json:"quantity"`
-	Timestamp time.Time `db:"timestamp" json:"timestamp"`
+	ID                 string              `db:"id" json:"reward_id"`
+	UserID             string              `db:"user_id" json:"user_id"`
+	Symbol             string              `db:"symbol" json:"symbol"`
+	Quantity           decimal.Decimal     `db:"quantity" json:"quantity"`
+	Timestamp          time.Time           `db:"timestamp" json:"timestamp"`
+	Status             string              `db:"status" json:"status"`
+	Source             *string             `db:"source" json:"source,omitempty"`
+	IdempotencyKey     *string             `db:"idempotency_key" json:"idempotency_key,omitempty"`
+	PriceINR           decimal.NullDecimal `db:"price_inr" json:"price_inr"`
+	ValueINR           decimal.NullDecimal `db:"value_inr" json:"value_inr"`
+	RequestedAmountINR decimal.NullDecimal `db:"requested_amount_inr" json:"requested_amount_inr"`
+	CampaignID         *string             `db:"campaign_id" json:"campaign_id,omitempty"`
+	VestingCliffMonths *int                `db:"vesting_cliff_months" json:"vesting_cliff_months,omitempty"`
+	VestingMonths      *int                `db:"vesting_months" json:"vesting_months,omitempty"`
+	CreatedAt          time.Time           `db:"created_at" json:"created_at"`
+	ReversedAt         *time.Time          `db:"reversed_at" json:"reversed_at,omitempty"`
+	ReversalReason     *string             `db:"reversal_reason" json:"reversal_reason,omitempty"`
+	ReversalNote       *string             `db:"reversal_note" json:"reversal_note,omitempty"`
+	ReversedBy         *string             `db:"reversed_by" json:"reversed_by,omitempty"`
 }
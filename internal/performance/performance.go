@@ -0,0 +1,205 @@
+// Package performance computes return and risk figures from a series of
+// daily portfolio values and the cash flows into and out of the portfolio.
+package performance
+
+import (
+	"math"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	dateLayout = "2006-01-02"
+	precision  = 12
+)
+
+// Point is a portfolio's value at the end of Date, a date at midnight UTC.
+type Point struct {
+	Date  time.Time
+	Value decimal.Decimal
+}
+
+// Flow is value moved into (positive) or out of (negative) the portfolio at
+// Time, for example a reward at its booking price or a sale.
+type Flow struct {
+	Time   time.Time
+	Amount decimal.Decimal
+}
+
+type Day struct {
+	Date   string          `json:"date"`
+	Return decimal.Decimal `json:"return"`
+}
+
+type Result struct {
+	From        string          `json:"from"`
+	To          string          `json:"to"`
+	StartValue  decimal.Decimal `json:"start_value_inr"`
+	EndValue    decimal.Decimal `json:"end_value_inr"`
+	NetFlows    decimal.Decimal `json:"net_flows_inr"`
+	TWR         decimal.Decimal `json:"time_weighted_return"`
+	XIRR        *float64        `json:"money_weighted_return"`
+	MaxDrawdown decimal.Decimal `json:"max_drawdown"`
+	PeakDate    string          `json:"drawdown_peak,omitempty"`
+	TroughDate  string          `json:"drawdown_trough,omitempty"`
+	BestDay     *Day            `json:"best_day"`
+	WorstDay    *Day            `json:"worst_day"`
+}
+
+func day(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Window selects the points from from to to inclusive (zero times leave that
+// end open) and prepends the base they are measured against: the last value
+// before from, or zero the day before the first point if there is none.
+func Window(points []Point, from, to time.Time) []Point {
+	var base *Point
+	res := []Point{}
+	for i := range points {
+		d := day(points[i].Date)
+		if !from.IsZero() && d.Before(day(from)) {
+			base = &points[i]
+			continue
+		}
+		if !to.IsZero() && d.After(day(to)) {
+			break
+		}
+		res = append(res, points[i])
+	}
+	if len(res) == 0 {
+		return res
+	}
+	if base == nil {
+		base = &Point{Date: day(res[0].Date).AddDate(0, 0, -1)}
+	}
+	return append([]Point{*base}, res...)
+}
+
+// flowDay is the date, in loc, a flow happened on, at midnight UTC like the
+// dates of points.
+func flowDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Compute measures performance over points, whose first element is the base
+// value (see Window). Flows are attributed to the day in loc they happened on
+// and treated as arriving at the end of that day; flows on or before the base
+// day are already part of the base value and are ignored.
+func Compute(points []Point, flows []Flow, loc *time.Location) Result {
+	res := Result{TWR: decimal.Zero, MaxDrawdown: decimal.Zero}
+	if len(points) < 2 {
+		return res
+	}
+	base, last := points[0], points[len(points)-1]
+	res.From = day(points[1].Date).Format(dateLayout)
+	res.To = day(last.Date).Format(dateLayout)
+	res.StartValue, res.EndValue = base.Value, last.Value
+
+	byDay := map[time.Time]decimal.Decimal{}
+	for _, f := range flows {
+		d := flowDay(f.Time, loc)
+		if !d.After(day(base.Date)) || d.After(day(last.Date)) {
+			continue
+		}
+		byDay[d] = byDay[d].Add(f.Amount)
+		res.NetFlows = res.NetFlows.Add(f.Amount)
+	}
+
+	one := decimal.NewFromInt(1)
+	index, peak := one, one
+	peakDate := res.From
+	for i := 1; i < len(points); i++ {
+		prev, cur := points[i-1], points[i]
+		if !prev.Value.IsPositive() {
+			continue
+		}
+		d := day(cur.Date)
+		r := cur.Value.Sub(byDay[d]).Sub(prev.Value).DivRound(prev.Value, precision)
+		date := d.Format(dateLayout)
+		if res.BestDay == nil || r.GreaterThan(res.BestDay.Return) {
+			res.BestDay = &Day{Date: date, Return: r}
+		}
+		if res.WorstDay == nil || r.LessThan(res.WorstDay.Return) {
+			res.WorstDay = &Day{Date: date, Return: r}
+		}
+
+		index = index.Mul(one.Add(r)).Round(precision)
+		if index.GreaterThan(peak) {
+			peak, peakDate = index, date
+			continue
+		}
+		if dd := peak.Sub(index).DivRound(peak, precision); dd.GreaterThan(res.MaxDrawdown) {
+			res.MaxDrawdown, res.PeakDate, res.TroughDate = dd, peakDate, date
+		}
+	}
+	res.TWR = index.Sub(one)
+
+	cash := []cashFlow{}
+	if base.Value.IsPositive() {
+		cash = append(cash, cashFlow{day(base.Date), -base.Value.InexactFloat64()})
+	}
+	for d, amt := range byDay {
+		cash = append(cash, cashFlow{d, -amt.InexactFloat64()})
+	}
+	cash = append(cash, cashFlow{day(last.Date), last.Value.InexactFloat64()})
+	if rate, ok := xirr(cash); ok {
+		res.XIRR = &rate
+	}
+	return res
+}
+
+// cashFlow is a flow from the investor's point of view: money put in is
+// negative, money (or value) taken out is positive.
+type cashFlow struct {
+	t      time.Time
+	amount float64
+}
+
+func npv(cash []cashFlow, rate float64) float64 {
+	t0 := cash[0].t
+	for _, c := range cash {
+		if c.t.Before(t0) {
+			t0 = c.t
+		}
+	}
+	sum := 0.0
+	for _, c := range cash {
+		years := c.t.Sub(t0).Hours() / 24 / 365
+		sum += c.amount / math.Pow(1+rate, years)
+	}
+	return sum
+}
+
+// xirr finds the annual rate at which the cash flows' net present value is
+// zero, by bisection. It fails unless money went both in and out.
+func xirr(cash []cashFlow) (float64, bool) {
+	var in, out bool
+	for _, c := range cash {
+		in = in || c.amount < 0
+		out = out || c.amount > 0
+	}
+	if !in || !out {
+		return 0, false
+	}
+	lo, hi := -0.999999, 1.0
+	for npv(cash, hi) > 0 && hi < 1e6 {
+		hi *= 2
+	}
+	flo, fhi := npv(cash, lo), npv(cash, hi)
+	if math.IsNaN(flo) || math.IsNaN(fhi) || (flo > 0) == (fhi > 0) {
+		return 0, false
+	}
+	for i := 0; i < 200 && hi-lo > 1e-10; i++ {
+		mid := (lo + hi) / 2
+		if (npv(cash, mid) > 0) == (flo > 0) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return math.Round((lo+hi)/2*1e8) / 1e8, true
+}
@@ -0,0 +1,113 @@
+package performance
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func d(day int) time.Time { return time.Date(2025, 1, day, 0, 0, 0, 0, time.UTC) }
+
+func pts(values ...int64) []Point {
+	res := []Point{}
+	for i, v := range values {
+		res = append(res, Point{Date: d(i + 1), Value: decimal.NewFromInt(v)})
+	}
+	return res
+}
+
+func TestComputeWithoutFlowsAfterStart(t *testing.T) {
+	points := Window(pts(1000, 1100, 990, 1210), time.Time{}, time.Time{})
+	res := Compute(points, []Flow{{Time: d(1).Add(10 * time.Hour), Amount: decimal.NewFromInt(1000)}}, time.UTC)
+
+	if res.From != "2025-01-01" || res.To != "2025-01-04" {
+		t.Errorf("range = %s..%s", res.From, res.To)
+	}
+	if res.TWR.StringFixed(6) != "0.210000" {
+		t.Errorf("TWR = %s, want 0.21", res.TWR)
+	}
+	if res.MaxDrawdown.StringFixed(6) != "0.100000" || res.PeakDate != "2025-01-02" || res.TroughDate != "2025-01-03" {
+		t.Errorf("drawdown = %s from %s to %s", res.MaxDrawdown, res.PeakDate, res.TroughDate)
+	}
+	if res.BestDay == nil || res.BestDay.Date != "2025-01-04" || res.WorstDay == nil || res.WorstDay.Date != "2025-01-03" {
+		t.Errorf("best %+v worst %+v", res.BestDay, res.WorstDay)
+	}
+	if !res.NetFlows.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("net flows = %s", res.NetFlows)
+	}
+	// 21% in three days annualises beyond what xirr searches.
+	if res.XIRR != nil {
+		t.Errorf("XIRR = %v, want none", *res.XIRR)
+	}
+}
+
+func TestComputeExcludesFlowsFromReturns(t *testing.T) {
+	// A second reward of 1000 on day 2 is not a gain.
+	points := Window(pts(1000, 2100), time.Time{}, time.Time{})
+	res := Compute(points, []Flow{
+		{Time: d(1), Amount: decimal.NewFromInt(1000)},
+		{Time: d(2), Amount: decimal.NewFromInt(1000)},
+	}, time.UTC)
+	if res.TWR.StringFixed(6) != "0.100000" {
+		t.Errorf("TWR = %s, want 0.10", res.TWR)
+	}
+	if !res.MaxDrawdown.IsZero() {
+		t.Errorf("drawdown = %s, want 0", res.MaxDrawdown)
+	}
+
+	// Over a year, the money-weighted return of 1000 growing to 1100 is 10%.
+	year := []Point{{Date: d(1).AddDate(0, 0, -1)}, {Date: d(1), Value: decimal.NewFromInt(1000)}, {Date: d(1).AddDate(0, 0, 365), Value: decimal.NewFromInt(1100)}}
+	res = Compute(year, []Flow{{Time: d(1), Amount: decimal.NewFromInt(1000)}}, time.UTC)
+	if res.XIRR == nil || math.Abs(*res.XIRR-0.1) > 1e-6 {
+		t.Errorf("XIRR = %v, want 0.1", res.XIRR)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	all := pts(10, 20, 30, 40)
+	w := Window(all, d(2), d(3))
+	if len(w) != 3 || !w[0].Value.Equal(decimal.NewFromInt(10)) || !w[2].Date.Equal(d(3)) {
+		t.Fatalf("unexpected window %+v", w)
+	}
+	if w := Window(all, d(10), time.Time{}); len(w) != 0 {
+		t.Fatalf("window past the end should be empty, got %+v", w)
+	}
+	// Flows before the range are already in the base value.
+	res := Compute(Window(all, d(3), time.Time{}), []Flow{{Time: d(1), Amount: decimal.NewFromInt(10)}}, time.UTC)
+	if !res.NetFlows.IsZero() || !res.StartValue.Equal(decimal.NewFromInt(20)) {
+		t.Fatalf("net flows %s start %s", res.NetFlows, res.StartValue)
+	}
+}
+
+func TestXIRR(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rate, ok := xirr([]cashFlow{{t0, -1000}, {t0.AddDate(0, 0, 365), 1100}})
+	if !ok || math.Abs(rate-0.1) > 1e-6 {
+		t.Fatalf("xirr = %v, %v; want 0.1", rate, ok)
+	}
+	rate, ok = xirr([]cashFlow{{t0, -1000}, {t0.AddDate(0, 0, 182), -1000}, {t0.AddDate(0, 0, 365), 1800}})
+	if !ok || rate >= 0 {
+		t.Fatalf("losing portfolio xirr = %v, %v; want negative", rate, ok)
+	}
+	if _, ok := xirr([]cashFlow{{t0, 1000}}); ok {
+		t.Fatal("xirr without money in should fail")
+	}
+}
+
+func TestFlowsFollowTimezone(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	// 20:00 UTC on 1 January is 01:30 IST on 2 January.
+	flow := []Flow{{Time: d(1).Add(20 * time.Hour), Amount: decimal.NewFromInt(1000)}}
+	points := Window(pts(1000, 2100), time.Time{}, time.Time{})
+
+	// In UTC the reward lands on day 1, so day 2's rise looks like a gain.
+	if res := Compute(points, flow, time.UTC); res.TWR.StringFixed(6) != "1.100000" {
+		t.Errorf("UTC TWR = %s, want 1.10", res.TWR)
+	}
+	// In IST it lands on day 2 and is not.
+	if res := Compute(points, flow, ist); res.TWR.StringFixed(6) != "0.100000" {
+		t.Errorf("IST TWR = %s, want 0.10", res.TWR)
+	}
+}
@@ -0,0 +1,108 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	RuleMaxReward       = "max_reward_inr"
+	RuleMaxUserDaily    = "max_user_daily_inr"
+	RuleMaxUserLifetime = "max_user_lifetime_inr"
+	RuleMaxSourceDaily  = "max_source_daily_inr"
+)
+
+// Limits caps the INR value (quantity x booking price) of rewards. A zero
+// limit is not enforced.
+type Limits struct {
+	MaxRewardINR       decimal.Decimal `json:"max_reward_inr"`
+	MaxUserDailyINR    decimal.Decimal `json:"max_user_daily_inr"`
+	MaxUserLifetimeINR decimal.Decimal `json:"max_user_lifetime_inr"`
+	MaxSourceDailyINR  decimal.Decimal `json:"max_source_daily_inr"`
+}
+
+// Config holds the default limits and per-symbol overrides. Fields left
+// zero in an override fall back to the default.
+type Config struct {
+	Default Limits            `json:"default"`
+	Symbols map[string]Limits `json:"symbols"`
+}
+
+func Load(path string) (Config, error) {
+	var cfg Config
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (c Config) For(symbol string) Limits {
+	l := c.Default
+	o, ok := c.Symbols[symbol]
+	if !ok {
+		return l
+	}
+	pick := func(override, def decimal.Decimal) decimal.Decimal {
+		if override.IsZero() {
+			return def
+		}
+		return override
+	}
+	return Limits{
+		MaxRewardINR:       pick(o.MaxRewardINR, l.MaxRewardINR),
+		MaxUserDailyINR:    pick(o.MaxUserDailyINR, l.MaxUserDailyINR),
+		MaxUserLifetimeINR: pick(o.MaxUserLifetimeINR, l.MaxUserLifetimeINR),
+		MaxSourceDailyINR:  pick(o.MaxSourceDailyINR, l.MaxSourceDailyINR),
+	}
+}
+
+// Usage is the INR value already granted, across all symbols, that counts
+// towards the limits.
+type Usage struct {
+	UserDayINR      decimal.Decimal
+	UserLifetimeINR decimal.Decimal
+	SourceDayINR    decimal.Decimal
+}
+
+type Violation struct {
+	Rule      string          `json:"rule"`
+	Limit     decimal.Decimal `json:"limit"`
+	Current   decimal.Decimal `json:"current"`
+	Attempted decimal.Decimal `json:"attempted"`
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("reward limit %s exceeded: %s + %s > %s", v.Rule, v.Current.StringFixed(4), v.Attempted.StringFixed(4), v.Limit.StringFixed(4))
+}
+
+// Evaluate returns a *Violation for the first limit that granting value on
+// top of u would exceed.
+func (l Limits) Evaluate(u Usage, value decimal.Decimal) error {
+	checks := []struct {
+		rule    string
+		limit   decimal.Decimal
+		current decimal.Decimal
+	}{
+		{RuleMaxReward, l.MaxRewardINR, decimal.Zero},
+		{RuleMaxUserDaily, l.MaxUserDailyINR, u.UserDayINR},
+		{RuleMaxUserLifetime, l.MaxUserLifetimeINR, u.UserLifetimeINR},
+		{RuleMaxSourceDaily, l.MaxSourceDailyINR, u.SourceDayINR},
+	}
+	for _, c := range checks {
+		if c.limit.IsPositive() && c.current.Add(value).GreaterThan(c.limit) {
+			return &Violation{Rule: c.rule, Limit: c.limit, Current: c.current, Attempted: value}
+		}
+	}
+	return nil
+}
+
+func (l Limits) Enforced() bool {
+	return l.MaxRewardINR.IsPositive() || l.MaxUserDailyINR.IsPositive() || l.MaxUserLifetimeINR.IsPositive() || l.MaxSourceDailyINR.IsPositive()
+}
@@ -0,0 +1,68 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+var d = decimal.RequireFromString
+
+func TestEvaluate(t *testing.T) {
+	l := Limits{
+		MaxRewardINR:       d("1000"),
+		MaxUserDailyINR:    d("2000"),
+		MaxUserLifetimeINR: d("10000"),
+		MaxSourceDailyINR:  d("50000"),
+	}
+	cases := []struct {
+		name  string
+		usage Usage
+		value string
+		rule  string
+	}{
+		{"within all limits", Usage{UserDayINR: d("500"), UserLifetimeINR: d("500")}, "1000", ""},
+		{"single reward too large", Usage{}, "1000.01", RuleMaxReward},
+		{"daily cap", Usage{UserDayINR: d("1500"), UserLifetimeINR: d("1500")}, "600", RuleMaxUserDaily},
+		{"lifetime cap", Usage{UserDayINR: d("0"), UserLifetimeINR: d("9500")}, "600", RuleMaxUserLifetime},
+		{"source cap", Usage{SourceDayINR: d("49900")}, "200", RuleMaxSourceDaily},
+	}
+	for _, tc := range cases {
+		err := l.Evaluate(tc.usage, d(tc.value))
+		if tc.rule == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected %v", tc.name, err)
+			}
+			continue
+		}
+		var v *Violation
+		if !errors.As(err, &v) || v.Rule != tc.rule {
+			t.Errorf("%s: got %v, want rule %s", tc.name, err, tc.rule)
+		}
+	}
+}
+
+func TestZeroLimitsAreUnlimited(t *testing.T) {
+	var l Limits
+	if l.Enforced() {
+		t.Fatal("zero limits should not be enforced")
+	}
+	if err := l.Evaluate(Usage{UserLifetimeINR: d("1e12")}, d("1e9")); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+}
+
+func TestForMergesSymbolOverrides(t *testing.T) {
+	cfg := Config{
+		Default: Limits{MaxRewardINR: d("1000"), MaxUserDailyINR: d("5000")},
+		Symbols: map[string]Limits{"MRF": {MaxRewardINR: d("150000")}},
+	}
+	got := cfg.For("MRF")
+	if !got.MaxRewardINR.Equal(d("150000")) || !got.MaxUserDailyINR.Equal(d("5000")) {
+		t.Fatalf("unexpected merged limits %+v", got)
+	}
+	if got := cfg.For("TCS"); !got.MaxRewardINR.Equal(d("1000")) {
+		t.Fatalf("unexpected default limits %+v", got)
+	}
+}
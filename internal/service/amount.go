@@ -0,0 +1,85 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// QuantityPlaces is the precision of reward quantities (NUMERIC(18,6)).
+const QuantityPlaces = 6
+
+// Rounding modes for converting an INR amount into a share quantity.
+const (
+	RoundDown     = "down"
+	RoundUp       = "up"
+	RoundHalfUp   = "half_up"
+	RoundHalfEven = "half_even"
+)
+
+// ParseRounding validates a rounding mode; empty means RoundDown, which never
+// grants more than the requested amount.
+func ParseRounding(mode string) (string, error) {
+	switch mode {
+	case "":
+		return RoundDown, nil
+	case RoundDown, RoundUp, RoundHalfUp, RoundHalfEven:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown rounding mode %q", mode)
+}
+
+// QuantityForAmount converts amountINR into a quantity at price, rounded to
+// QuantityPlaces with mode. The residue is the requested amount minus the
+// value actually granted; it is negative when rounding up.
+func QuantityForAmount(amountINR, price decimal.Decimal, mode string) (decimal.Decimal, decimal.Decimal, error) {
+	return QuantityForAmountAt(amountINR, price, mode, QuantityPlaces)
+}
+
+// QuantityForAmountAt is QuantityForAmount for a stock traded in fewer
+// decimal places, such as whole shares (places 0).
+func QuantityForAmountAt(amountINR, price decimal.Decimal, mode string, places int32) (decimal.Decimal, decimal.Decimal, error) {
+	if !price.IsPositive() {
+		return decimal.Zero, decimal.Zero, fmt.Errorf("price must be positive, got %s", price)
+	}
+	if places < 0 || places > QuantityPlaces {
+		return decimal.Zero, decimal.Zero, fmt.Errorf("places must be between 0 and %d, got %d", QuantityPlaces, places)
+	}
+	// The quotient is only approximated to a few extra places, so the
+	// candidate floor is corrected against the exact product before a mode
+	// picks between it and the next step up.
+	unit := decimal.New(1, -places)
+	lo := amountINR.DivRound(price, QuantityPlaces+4).RoundDown(places)
+	for lo.Mul(price).GreaterThan(amountINR) {
+		lo = lo.Sub(unit)
+	}
+	for lo.Add(unit).Mul(price).LessThanOrEqual(amountINR) {
+		lo = lo.Add(unit)
+	}
+	hi := lo.Add(unit)
+	below, above := amountINR.Sub(lo.Mul(price)), hi.Mul(price).Sub(amountINR)
+	var q decimal.Decimal
+	switch mode {
+	case RoundDown, "":
+		q = lo
+	case RoundUp:
+		q = hi
+		if below.IsZero() {
+			q = lo
+		}
+	case RoundHalfUp, RoundHalfEven:
+		switch c := below.Cmp(above); {
+		case c < 0:
+			q = lo
+		case c > 0:
+			q = hi
+		case mode == RoundHalfUp || !lo.Shift(places).Mod(decimal.NewFromInt(2)).IsZero():
+			q = hi
+		default:
+			q = lo
+		}
+	default:
+		return decimal.Zero, decimal.Zero, fmt.Errorf("unknown rounding mode %q", mode)
+	}
+	return q, amountINR.Sub(q.Mul(price)), nil
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestQuantityForAmount(t *testing.T) {
+	d := decimal.RequireFromString
+	cases := []struct {
+		mode, amount, price, qty, residue string
+	}{
+		{RoundDown, "100", "3456.7800", "0.028928", "0.00226816"},
+		{RoundUp, "100", "3456.7800", "0.028929", "-0.00118862"},
+		{RoundHalfUp, "100", "3456.7800", "0.028929", "-0.00118862"},
+		{RoundHalfEven, "0.0000025", "1", "0.000002", "0.0000005"},
+		{RoundHalfUp, "0.0000025", "1", "0.000003", "-0.0000005"},
+		{RoundDown, "500", "250", "2", "0"},
+	}
+	for _, tc := range cases {
+		q, residue, err := QuantityForAmount(d(tc.amount), d(tc.price), tc.mode)
+		if err != nil {
+			t.Fatalf("%s %s@%s: %v", tc.mode, tc.amount, tc.price, err)
+		}
+		if !q.Equal(d(tc.qty)) {
+			t.Errorf("%s %s@%s: quantity %s, want %s", tc.mode, tc.amount, tc.price, q, tc.qty)
+		}
+		if !residue.Equal(d(tc.residue)) {
+			t.Errorf("%s %s@%s: residue %s, want %s", tc.mode, tc.amount, tc.price, residue, tc.residue)
+		}
+	}
+}
+
+func TestQuantityForAmountAtLotPrecision(t *testing.T) {
+	d := decimal.RequireFromString
+	q, residue, err := QuantityForAmountAt(d("10000"), d("3456.78"), RoundDown, 0)
+	if err != nil || !q.Equal(d("2")) || !residue.Equal(d("3086.44")) {
+		t.Fatalf("whole shares: got %s residue %s (err %v), want 2 residue 3086.44", q, residue, err)
+	}
+	q, _, err = QuantityForAmountAt(d("100"), d("3456.78"), RoundUp, 2)
+	if err != nil || !q.Equal(d("0.03")) {
+		t.Fatalf("two places rounding up: got %s (err %v), want 0.03", q, err)
+	}
+	if _, _, err := QuantityForAmountAt(d("100"), d("1"), RoundDown, 7); err == nil {
+		t.Error("more places than quantities are stored with should fail")
+	}
+}
+
+func TestQuantityForAmountNearUnitBoundary(t *testing.T) {
+	d := decimal.RequireFromString
+	// Both quotients are within 1e-11 of 1, past the precision the division
+	// is carried to.
+	q, residue, err := QuantityForAmount(d("0.99999999999"), d("1"), RoundDown)
+	if err != nil || !q.Equal(d("0.999999")) || residue.IsNegative() {
+		t.Fatalf("rounding down: got %s residue %s (err %v), want 0.999999 and a non-negative residue", q, residue, err)
+	}
+	q, residue, err = QuantityForAmount(d("1.00000000001"), d("1"), RoundUp)
+	if err != nil || !q.Equal(d("1.000001")) || residue.IsPositive() {
+		t.Fatalf("rounding up: got %s residue %s (err %v), want 1.000001 and a non-positive residue", q, residue, err)
+	}
+	q, _, err = QuantityForAmount(d("0.00000249999999999"), d("1"), RoundHalfUp)
+	if err != nil || !q.Equal(d("0.000002")) {
+		t.Fatalf("rounding half up just below a half: got %s (err %v), want 0.000002", q, err)
+	}
+}
+
+func TestQuantityForAmountRejectsBadInput(t *testing.T) {
+	if _, _, err := QuantityForAmount(decimal.NewFromInt(100), decimal.Zero, RoundDown); err == nil {
+		t.Error("zero price should fail")
+	}
+	if _, err := ParseRounding("sideways"); err == nil {
+		t.Error("unknown rounding mode should fail")
+	}
+	if m, err := ParseRounding(""); err != nil || m != RoundDown {
+		t.Errorf("default rounding = %q, %v", m, err)
+	}
+}
@@ -0,0 +1,14 @@
+package service
+
+import "github.com/shopspring/decimal"
+
+// DefaultSellFeeRate matches the 1% fee charged when rewards are bought.
+var DefaultSellFeeRate = decimal.NewFromFloat(0.01)
+
+// SaleProceeds prices a sell-back of quantity shares at price. Gross and fees
+// are rounded to paise; net is what the user's wallet receives.
+func SaleProceeds(quantity, price, feeRate decimal.Decimal) (gross, fees, net decimal.Decimal) {
+	gross = quantity.Mul(price).Round(4)
+	fees = gross.Mul(feeRate).Round(4)
+	return gross, fees, gross.Sub(fees)
+}
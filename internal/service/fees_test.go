@@ -0,0 +1,25 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestSaleProceeds(t *testing.T) {
+	gross, fees, net := SaleProceeds(decimal.RequireFromString("1.5"), decimal.RequireFromString("2450.1234"), DefaultSellFeeRate)
+	if want := "3675.1851"; gross.StringFixed(4) != want {
+		t.Errorf("gross = %s, want %s", gross.StringFixed(4), want)
+	}
+	if want := "36.7519"; fees.StringFixed(4) != want {
+		t.Errorf("fees = %s, want %s", fees.StringFixed(4), want)
+	}
+	if want := "3638.4332"; net.StringFixed(4) != want {
+		t.Errorf("net = %s, want %s", net.StringFixed(4), want)
+	}
+
+	_, fees, net = SaleProceeds(decimal.NewFromInt(2), decimal.NewFromInt(100), decimal.Zero)
+	if !fees.IsZero() || !net.Equal(decimal.NewFromInt(200)) {
+		t.Errorf("zero fee rate: fees = %s, net = %s", fees, net)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"math/rand"
 	"time"
 
+	"stocky/internal/calendar"
 	"stocky/internal/database"
 
 	"github.com/shopspring/decimal"
@@ -17,12 +18,42 @@ type PriceProvider interface {
 }
 
 type CleanPriceService struct {
-	repo *database.Repo
-	log  *logrus.Logger
+	repo     *database.Repo
+	log      *logrus.Logger
+	calendar *calendar.Calendar
+	guard    *PriceGuard
 }
 
-func NewCleanPriceService(r *database.Repo, log *logrus.Logger) *CleanPriceService {
-	return &CleanPriceService{repo: r, log: log}
+type PriceOption func(*CleanPriceService)
+
+// WithMarketCalendar stops the service from generating prices while the
+// exchange is closed.
+func WithMarketCalendar(c *calendar.Calendar) PriceOption {
+	return func(p *CleanPriceService) { p.calendar = c }
+}
+
+// WithPriceGuard routes generated quotes through g before they are stored.
+func WithPriceGuard(g *PriceGuard) PriceOption {
+	return func(p *CleanPriceService) { p.guard = g }
+}
+
+func NewCleanPriceService(r *database.Repo, log *logrus.Logger, opts ...PriceOption) *CleanPriceService {
+	p := &CleanPriceService{repo: r, log: log}
+	for _, o := range opts {
+		o(p)
+	}
+	return p
+}
+
+func (p *CleanPriceService) marketOpen(t time.Time) bool {
+	return p.calendar == nil || p.calendar.IsOpen(t)
+}
+
+func (p *CleanPriceService) store(ctx context.Context, symbol string, price decimal.Decimal, ts time.Time) (bool, error) {
+	if p.guard == nil {
+		return true, p.repo.UpsertPrice(ctx, symbol, price, ts)
+	}
+	return p.guard.Store(ctx, symbol, price, ts)
 }
 
 func (p *CleanPriceService) GetPrice(ctx context.Context, symbol string) (decimal.Decimal, time.Time, error) {
@@ -30,9 +61,20 @@ func (p *CleanPriceService) GetPrice(ctx context.Context, symbol string) (decima
 	if err == nil && time.Since(ts) < 15*time.Minute {
 		return price, ts, nil
 	}
+	// Outside market hours the last traded price is the current price.
+	if err == nil && !p.marketOpen(time.Now()) {
+		return price, ts, nil
+	}
+	last, lastTS, lastErr := price, ts, err
 	val := decimal.NewFromFloat(50 + rand.Float64()*(5000-50))
 	ts = time.Now().UTC()
-	_ = p.repo.UpsertPrice(ctx, symbol, val, ts) 
+	stored, err := p.store(ctx, symbol, val, ts)
+	if err != nil {
+		p.log.Warnf("store price for %s: %v", symbol, err)
+	}
+	if !stored && lastErr == nil {
+		return last, lastTS, nil
+	}
 	return val, ts, nil
 }
 
@@ -46,6 +88,10 @@ func (p *CleanPriceService) Start(ctx context.Context, interval time.Duration) {
 				p.log.Info("price updater stopping")
 				return
 			case <-ticker.C:
+				if !p.marketOpen(time.Now()) {
+					p.log.Debug("market closed; skipping price update")
+					continue
+				}
 				symbols, err := p.repo.GetAllSymbols(ctx)
 				if err != nil {
 					p.log.Warnf("failed to fetch symbols: %v", err)
@@ -53,7 +99,9 @@ func (p *CleanPriceService) Start(ctx context.Context, interval time.Duration) {
 				}
 				for _, s := range symbols {
 					val := decimal.NewFromFloat(50 + rand.Float64()*(5000-50))
-					_ = p.repo.UpsertPrice(ctx, s, val, time.Now().UTC())
+					if _, err := p.store(ctx, s, val, time.Now().UTC()); err != nil {
+						p.log.Warnf("store price for %s: %v", s, err)
+					}
 				}
 			}
 		}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"time"
+
+	"stocky/internal/database"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ReasonNonPositive = "non_positive"
+	ReasonSuspectMove = "suspect_move"
+)
+
+var guardMetrics = expvar.NewMap("price_guard")
+
+// PriceGuard validates quotes before they reach price_history. Rejected and
+// suspect quotes are quarantined so they are never used to book rewards.
+type PriceGuard struct {
+	repo       *database.Repo
+	log        *logrus.Logger
+	maxMovePct decimal.Decimal
+}
+
+func NewPriceGuard(r *database.Repo, log *logrus.Logger, maxMovePct decimal.Decimal) *PriceGuard {
+	return &PriceGuard{repo: r, log: log, maxMovePct: maxMovePct}
+}
+
+// CheckQuote classifies price against the last accepted price. It returns the
+// quarantine reason ("" when the quote is acceptable) and the percentage move.
+func CheckQuote(last *decimal.Decimal, price, maxMovePct decimal.Decimal) (string, *decimal.Decimal) {
+	if !price.IsPositive() {
+		return ReasonNonPositive, nil
+	}
+	if last == nil || !last.IsPositive() {
+		return "", nil
+	}
+	move := price.Sub(*last).Div(*last).Mul(decimal.NewFromInt(100)).Round(4)
+	if maxMovePct.IsPositive() && move.Abs().GreaterThan(maxMovePct) {
+		return ReasonSuspectMove, &move
+	}
+	return "", &move
+}
+
+// Store persists price if it passes the checks and reports whether it did.
+func (g *PriceGuard) Store(ctx context.Context, symbol string, price decimal.Decimal, ts time.Time) (bool, error) {
+	var last *decimal.Decimal
+	lp, _, err := g.repo.GetLatestPrice(ctx, symbol)
+	switch {
+	case err == nil:
+		last = &lp
+	case !errors.Is(err, sql.ErrNoRows):
+		return false, err
+	}
+
+	reason, move := CheckQuote(last, price, g.maxMovePct)
+	if reason == "" {
+		if err := g.repo.UpsertPrice(ctx, symbol, price, ts); err != nil {
+			return false, err
+		}
+		guardMetrics.Add("accepted", 1)
+		return true, nil
+	}
+
+	guardMetrics.Add(reason, 1)
+	g.log.WithFields(logrus.Fields{
+		"symbol":     symbol,
+		"price":      price.StringFixed(4),
+		"last_price": last,
+		"move_pct":   move,
+		"reason":     reason,
+	}).Warn("price quote quarantined")
+	if err := g.repo.QuarantinePrice(ctx, database.QuarantinedPrice{
+		Symbol:       symbol,
+		PriceINR:     price,
+		LastPriceINR: last,
+		MovePct:      move,
+		Reason:       reason,
+		Timestamp:    ts,
+	}); err != nil {
+		return false, err
+	}
+	return false, nil
+}
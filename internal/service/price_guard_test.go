@@ -0,0 +1,40 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestCheckQuote(t *testing.T) {
+	d := decimal.RequireFromString
+	last := d("100")
+	cases := []struct {
+		name   string
+		last   *decimal.Decimal
+		price  string
+		reason string
+		move   string
+	}{
+		{"zero", &last, "0", ReasonNonPositive, ""},
+		{"negative", &last, "-5", ReasonNonPositive, ""},
+		{"no history", nil, "2500", "", ""},
+		{"small move", &last, "105", "", "5"},
+		{"at threshold", &last, "80", "", "-20"},
+		{"ten x jump", &last, "1000", ReasonSuspectMove, "900"},
+		{"crash", &last, "50", ReasonSuspectMove, "-50"},
+	}
+	for _, tc := range cases {
+		reason, move := CheckQuote(tc.last, d(tc.price), d("20"))
+		if reason != tc.reason {
+			t.Errorf("%s: reason %q, want %q", tc.name, reason, tc.reason)
+		}
+		if tc.move == "" {
+			if move != nil {
+				t.Errorf("%s: expected no move, got %s", tc.name, move)
+			}
+		} else if move == nil || !move.Equal(d(tc.move)) {
+			t.Errorf("%s: move %v, want %s", tc.name, move, tc.move)
+		}
+	}
+}
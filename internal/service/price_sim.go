@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"stocky/internal/calendar"
+	"stocky/internal/database"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+// TradingYear is the amount of session time in a year (252 NSE sessions of
+// 6h15m); GBM drift and volatility are annualised against it.
+const TradingYear = 252 * (6*time.Hour + 15*time.Minute)
+
+type SymbolParams struct {
+	Drift      float64         `json:"drift"`
+	Volatility float64         `json:"volatility"`
+	StartPrice decimal.Decimal `json:"start_price"`
+}
+
+type SimConfig struct {
+	Seed    int64                   `json:"seed"`
+	Default SymbolParams            `json:"default"`
+	Symbols map[string]SymbolParams `json:"symbols"`
+}
+
+func DefaultSimConfig() SimConfig {
+	return SimConfig{
+		Seed:    1,
+		Default: SymbolParams{Drift: 0.08, Volatility: 0.25, StartPrice: decimal.NewFromInt(1000)},
+		Symbols: map[string]SymbolParams{},
+	}
+}
+
+func LoadSimConfig(path string) (SimConfig, error) {
+	cfg := DefaultSimConfig()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, err
+	}
+	if cfg.Symbols == nil {
+		cfg.Symbols = map[string]SymbolParams{}
+	}
+	return cfg, nil
+}
+
+func (c SimConfig) params(symbol string) SymbolParams {
+	if p, ok := c.Symbols[symbol]; ok {
+		if p.StartPrice.IsZero() {
+			p.StartPrice = c.Default.StartPrice
+		}
+		return p
+	}
+	return c.Default
+}
+
+type gbmPath struct {
+	rng      *rand.Rand
+	params   SymbolParams
+	price    float64
+	ts       time.Time
+	proposed float64
+}
+
+// Simulator evolves one geometric Brownian motion per symbol. Every symbol
+// has its own random stream derived from the seed and the symbol name, so a
+// symbol's path does not depend on which other symbols are simulated.
+type Simulator struct {
+	mu    sync.Mutex
+	cfg   SimConfig
+	paths map[string]*gbmPath
+}
+
+func NewSimulator(cfg SimConfig) *Simulator {
+	return &Simulator{cfg: cfg, paths: map[string]*gbmPath{}}
+}
+
+func (s *Simulator) path(symbol string, now time.Time) *gbmPath {
+	p, ok := s.paths[symbol]
+	if !ok {
+		h := fnv.New64a()
+		h.Write([]byte(symbol))
+		params := s.cfg.params(symbol)
+		start, _ := params.StartPrice.Float64()
+		p = &gbmPath{
+			rng:    rand.New(rand.NewSource(s.cfg.Seed ^ int64(h.Sum64()))),
+			params: params,
+			price:  start,
+			ts:     now,
+		}
+		s.paths[symbol] = p
+	}
+	return p
+}
+
+func (s *Simulator) Known(symbol string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.paths[symbol]
+	return ok
+}
+
+// Resume restarts symbol's path from price, keeping its random stream.
+func (s *Simulator) Resume(symbol string, price decimal.Decimal, ts time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.path(symbol, ts)
+	p.price, _ = price.Float64()
+	p.ts = ts
+}
+
+func (s *Simulator) Price(symbol string, now time.Time) (decimal.Decimal, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.path(symbol, now)
+	return decimal.NewFromFloat(p.price).Round(4), p.ts
+}
+
+// Step advances symbol by dt of trading time and returns the new price.
+func (s *Simulator) Step(symbol string, dt time.Duration, now time.Time) decimal.Decimal {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.path(symbol, now)
+	price := p.propose(dt)
+	p.accept(now)
+	return price
+}
+
+// Propose draws symbol's price dt of trading time ahead without moving its
+// path there; Accept does that once the quote has been stored. A rejected
+// quote is simply never accepted, so later steps do not build on it. The
+// random stream advances either way.
+func (s *Simulator) Propose(symbol string, dt time.Duration, now time.Time) decimal.Decimal {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.path(symbol, now).propose(dt)
+}
+
+// Accept moves symbol's path to the price last proposed.
+func (s *Simulator) Accept(symbol string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path(symbol, now).accept(now)
+}
+
+func (p *gbmPath) propose(dt time.Duration) decimal.Decimal {
+	t := float64(dt) / float64(TradingYear)
+	mu, sigma := p.params.Drift, p.params.Volatility
+	z := p.rng.NormFloat64()
+	p.proposed = p.price * math.Exp((mu-sigma*sigma/2)*t+sigma*math.Sqrt(t)*z)
+	return decimal.NewFromFloat(p.proposed).Round(4)
+}
+
+func (p *gbmPath) accept(now time.Time) {
+	if p.proposed > 0 {
+		p.price, p.ts = p.proposed, now
+		p.proposed = 0
+	}
+}
+
+type SimulatedPriceService struct {
+	repo     *database.Repo
+	log      *logrus.Logger
+	sim      *Simulator
+	calendar *calendar.Calendar
+	guard    *PriceGuard
+}
+
+func NewSimulatedPriceService(r *database.Repo, log *logrus.Logger, cfg SimConfig, cal *calendar.Calendar, guard *PriceGuard) *SimulatedPriceService {
+	return &SimulatedPriceService{repo: r, log: log, sim: NewSimulator(cfg), calendar: cal, guard: guard}
+}
+
+// store persists a simulated quote through the guard, if any, and reports
+// whether it was stored.
+func (p *SimulatedPriceService) store(ctx context.Context, symbol string, price decimal.Decimal, ts time.Time) bool {
+	stored := true
+	var err error
+	if p.guard == nil {
+		err = p.repo.UpsertPrice(ctx, symbol, price, ts)
+	} else {
+		stored, err = p.guard.Store(ctx, symbol, price, ts)
+	}
+	if err != nil {
+		p.log.Warnf("store simulated price for %s: %v", symbol, err)
+		return false
+	}
+	return stored
+}
+
+// ensure continues a symbol from its last stored quote, if any, so restarts
+// do not jump back to the configured start price.
+func (p *SimulatedPriceService) ensure(ctx context.Context, symbol string) {
+	if p.sim.Known(symbol) {
+		return
+	}
+	if price, ts, err := p.repo.GetLatestPrice(ctx, symbol); err == nil {
+		p.sim.Resume(symbol, price, ts)
+		return
+	}
+	price, ts := p.sim.Price(symbol, time.Now().UTC())
+	p.store(ctx, symbol, price, ts)
+}
+
+// GetPrice returns the last stored quote rather than the simulator state, so
+// a quote the guard quarantined is never used for booking.
+func (p *SimulatedPriceService) GetPrice(ctx context.Context, symbol string) (decimal.Decimal, time.Time, error) {
+	p.ensure(ctx, symbol)
+	return p.repo.GetLatestPrice(ctx, symbol)
+}
+
+func (p *SimulatedPriceService) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				p.log.Info("simulated market stopping")
+				return
+			case <-ticker.C:
+				if p.calendar != nil && !p.calendar.IsOpen(time.Now()) {
+					p.log.Debug("market closed; skipping simulated tick")
+					continue
+				}
+				symbols, err := p.repo.GetAllSymbols(ctx)
+				if err != nil {
+					p.log.Warnf("failed to fetch symbols: %v", err)
+					continue
+				}
+				now := time.Now().UTC()
+				for _, s := range symbols {
+					p.ensure(ctx, s)
+					if p.store(ctx, s, p.sim.Propose(s, interval, now), now) {
+						p.sim.Accept(s, now)
+					}
+				}
+			}
+		}
+	}()
+}
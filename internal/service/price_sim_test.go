@@ -0,0 +1,88 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestSimulatorIsDeterministic(t *testing.T) {
+	cfg := DefaultSimConfig()
+	cfg.Seed = 42
+	now := time.Date(2025, 1, 2, 4, 0, 0, 0, time.UTC)
+
+	a, b := NewSimulator(cfg), NewSimulator(cfg)
+	// Touch another symbol first in b: per-symbol streams must be independent.
+	b.Step("INFY", time.Hour, now)
+	for i := 0; i < 50; i++ {
+		pa := a.Step("TCS", time.Hour, now)
+		pb := b.Step("TCS", time.Hour, now)
+		if !pa.Equal(pb) {
+			t.Fatalf("step %d: %s != %s", i, pa, pb)
+		}
+	}
+}
+
+func TestSimulatorGoldenValues(t *testing.T) {
+	cfg := SimConfig{
+		Seed:    42,
+		Default: SymbolParams{Drift: 0.08, Volatility: 0.25, StartPrice: decimal.NewFromInt(1000)},
+	}
+	sim := NewSimulator(cfg)
+	now := time.Date(2025, 1, 2, 4, 0, 0, 0, time.UTC)
+	want := []string{"998.8364", "995.7948", "1000.7958", "1001.6548", "997.1063"}
+	for i, w := range want {
+		got := sim.Step("TCS", time.Hour, now)
+		if got.String() != w {
+			t.Fatalf("step %d: got %s, want %s", i, got, w)
+		}
+	}
+}
+
+func TestSimulatorZeroVolatilityFollowsDrift(t *testing.T) {
+	cfg := SimConfig{
+		Default: SymbolParams{Drift: 0.10, Volatility: 0, StartPrice: decimal.NewFromInt(100)},
+	}
+	sim := NewSimulator(cfg)
+	var got decimal.Decimal
+	for i := 0; i < 252; i++ {
+		got = sim.Step("X", 6*time.Hour+15*time.Minute, time.Now())
+	}
+	// A full trading year at 10% continuous drift: 100 * e^0.1.
+	if want := decimal.RequireFromString("110.5171"); !got.Equal(want) {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestSimConfigSymbolOverride(t *testing.T) {
+	cfg := DefaultSimConfig()
+	cfg.Symbols["RELIANCE"] = SymbolParams{Drift: 0.05, Volatility: 0.2, StartPrice: decimal.NewFromInt(1400)}
+	sim := NewSimulator(cfg)
+	if p, _ := sim.Price("RELIANCE", time.Now()); !p.Equal(decimal.NewFromInt(1400)) {
+		t.Fatalf("expected override start price, got %s", p)
+	}
+	if p, _ := sim.Price("TCS", time.Now()); !p.Equal(decimal.NewFromInt(1000)) {
+		t.Fatalf("expected default start price, got %s", p)
+	}
+}
+
+func TestSimulatorRejectedProposalDoesNotMovePath(t *testing.T) {
+	cfg := DefaultSimConfig()
+	now := time.Date(2025, 1, 2, 4, 0, 0, 0, time.UTC)
+	sim := NewSimulator(cfg)
+	start, _ := sim.Price("TCS", now)
+
+	proposed := sim.Propose("TCS", time.Hour, now.Add(time.Hour))
+	if got, _ := sim.Price("TCS", now); !got.Equal(start) {
+		t.Fatalf("an unaccepted proposal moved the path to %s", got)
+	}
+	next := sim.Propose("TCS", time.Hour, now.Add(2*time.Hour))
+	sim.Accept("TCS", now.Add(2*time.Hour))
+	if got, ts := sim.Price("TCS", now); !got.Equal(next) || !ts.Equal(now.Add(2*time.Hour)) {
+		t.Fatalf("accept should move the path to %s, got %s at %s", next, got, ts)
+	}
+	if next.Equal(proposed) {
+		t.Fatal("each proposal should take a fresh draw")
+	}
+}
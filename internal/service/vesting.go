@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"stocky/internal/database"
+	"stocky/internal/events"
+
+	"github.com/sirupsen/logrus"
+)
+
+// VestingJob periodically moves vested tranches of reward grants into
+// holdings and tells connected clients about them.
+type VestingJob struct {
+	repo      *database.Repo
+	log       *logrus.Logger
+	bus       *events.Bus
+	BatchSize int
+}
+
+func NewVestingJob(r *database.Repo, log *logrus.Logger, bus *events.Bus) *VestingJob {
+	return &VestingJob{repo: r, log: log, bus: bus, BatchSize: 100}
+}
+
+func (j *VestingJob) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				j.log.Info("vesting job stopping")
+				return
+			case <-ticker.C:
+				j.runOnce(ctx)
+			}
+		}
+	}()
+}
+
+func (j *VestingJob) runOnce(ctx context.Context) {
+	for {
+		released, err := j.repo.VestDue(ctx, time.Now().UTC(), j.BatchSize)
+		if err != nil {
+			j.log.Warnf("vesting run failed: %v", err)
+			return
+		}
+		for _, rel := range released {
+			j.log.Infof("vested %s %s for %s (reward %s)", rel.Quantity.StringFixed(6), rel.Symbol, rel.UserID, rel.RewardID)
+			if j.bus != nil {
+				j.bus.Publish(events.Event{Type: events.RewardVested, UserID: rel.UserID, Data: rel})
+			}
+		}
+		if len(released) < j.BatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"stocky/internal/database"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	SignatureHeader = "X-Stocky-Signature"
+	TimestampHeader = "X-Stocky-Timestamp"
+	EventHeader     = "X-Stocky-Event"
+	DeliveryHeader  = "X-Stocky-Delivery"
+)
+
+// SignPayload returns the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with
+// the subscription secret. Receivers recompute it to authenticate deliveries.
+func SignPayload(secret string, ts int64, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(strconv.FormatInt(ts, 10)))
+	m.Write([]byte("."))
+	m.Write(body)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+type WebhookDispatcher struct {
+	repo        *database.Repo
+	log         *logrus.Logger
+	client      *http.Client
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	BatchSize   int
+}
+
+func NewWebhookDispatcher(r *database.Repo, log *logrus.Logger) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		repo:        r,
+		log:         log,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: 8,
+		BaseBackoff: 30 * time.Second,
+		MaxBackoff:  6 * time.Hour,
+		BatchSize:   50,
+	}
+}
+
+// Backoff returns the wait before the next try after the given number of
+// failed attempts: BaseBackoff doubled per attempt, capped at MaxBackoff.
+func (d *WebhookDispatcher) Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	wait := d.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= d.MaxBackoff {
+			return d.MaxBackoff
+		}
+	}
+	return wait
+}
+
+func (d *WebhookDispatcher) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				d.log.Info("webhook dispatcher stopping")
+				return
+			case <-ticker.C:
+				d.runOnce(ctx)
+			}
+		}
+	}()
+}
+
+func (d *WebhookDispatcher) runOnce(ctx context.Context) {
+	if _, err := d.repo.FanOutOutbox(ctx, d.BatchSize); err != nil {
+		d.log.Warnf("webhook fan-out failed: %v", err)
+	}
+	due, err := d.repo.ClaimDueDeliveries(ctx, d.BatchSize, time.Minute)
+	if err != nil {
+		d.log.Warnf("claim webhook deliveries failed: %v", err)
+		return
+	}
+	for _, del := range due {
+		d.deliver(ctx, del)
+	}
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, del database.PendingDelivery) {
+	attempt := del.Attempts + 1
+	started := time.Now()
+	code, err := d.send(ctx, del)
+	dur := time.Since(started)
+
+	success := err == nil
+	errMsg := ""
+	var retryAt time.Time
+	if !success {
+		errMsg = err.Error()
+		if attempt < d.MaxAttempts {
+			retryAt = time.Now().Add(d.Backoff(attempt))
+		}
+		d.log.Warnf("webhook delivery %s attempt %d failed: %v", del.ID, attempt, err)
+	}
+	if err := d.repo.RecordDeliveryAttempt(ctx, del.ID, attempt, code, errMsg, dur, success, retryAt); err != nil {
+		d.log.Errorf("record webhook attempt %s failed: %v", del.ID, err)
+	}
+}
+
+// send posts the signed event envelope and treats any non-2xx as a failure.
+func (d *WebhookDispatcher) send(ctx context.Context, del database.PendingDelivery) (int, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"id":         del.OutboxID,
+		"type":       del.EventType,
+		"created_at": del.CreatedAt,
+		"data":       del.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+	ts := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, del.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, del.EventType)
+	req.Header.Set(DeliveryHeader, del.ID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(SignatureHeader, "sha256="+SignPayload(del.Secret, ts, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
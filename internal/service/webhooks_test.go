@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"stocky/internal/database"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestWebhookSendSignsPayload(t *testing.T) {
+	secret := "s3cret"
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		if err != nil {
+			t.Errorf("bad timestamp header: %v", err)
+		}
+		if want := "sha256=" + SignPayload(secret, ts, body); r.Header.Get(SignatureHeader) != want {
+			t.Errorf("signature mismatch: got %s want %s", r.Header.Get(SignatureHeader), want)
+		}
+		if r.Header.Get(EventHeader) != "reward.created" {
+			t.Errorf("unexpected event header %q", r.Header.Get(EventHeader))
+		}
+		_ = json.Unmarshal(body, &got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	d := NewWebhookDispatcher(nil, logrus.New())
+	code, err := d.send(context.Background(), database.PendingDelivery{
+		ID:        "d1",
+		OutboxID:  7,
+		URL:       srv.URL,
+		Secret:    secret,
+		EventType: "reward.created",
+		Payload:   json.RawMessage(`{"reward_id":"r1"}`),
+		CreatedAt: time.Now(),
+	})
+	if err != nil || code != http.StatusNoContent {
+		t.Fatalf("send: code=%d err=%v", code, err)
+	}
+	data, _ := got["data"].(map[string]interface{})
+	if got["type"] != "reward.created" || data["reward_id"] != "r1" {
+		t.Fatalf("unexpected envelope %v", got)
+	}
+}
+
+func TestWebhookSendFailsOnNon2xx(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	d := NewWebhookDispatcher(nil, logrus.New())
+	code, err := d.send(context.Background(), database.PendingDelivery{ID: "d1", URL: srv.URL, Payload: json.RawMessage(`{}`)})
+	if err == nil || code != http.StatusBadGateway {
+		t.Fatalf("expected failure with 502, got code=%d err=%v", code, err)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	d := &WebhookDispatcher{BaseBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	cases := map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 20: 10 * time.Second}
+	for attempts, want := range cases {
+		if got := d.Backoff(attempts); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
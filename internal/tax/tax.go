@@ -0,0 +1,150 @@
+package tax
+
+import (
+	"fmt"
+	"time"
+
+	"stocky/internal/calendar"
+
+	"github.com/shopspring/decimal"
+)
+
+// IST is Indian Standard Time; dates for tax purposes are Indian calendar
+// dates.
+var IST = calendar.IST
+
+const (
+	ShortTerm = "STCG"
+	LongTerm  = "LTCG"
+)
+
+// FiscalYear is an Indian financial year, 1 April to 31 March.
+type FiscalYear struct {
+	Label string
+	Start time.Time // inclusive
+	End   time.Time // exclusive
+}
+
+// ParseFY parses a label such as "2025-26".
+func ParseFY(s string) (FiscalYear, error) {
+	var start, end int
+	if n, err := fmt.Sscanf(s, "%4d-%2d", &start, &end); err != nil || n != 2 || len(s) != 7 {
+		return FiscalYear{}, fmt.Errorf("invalid financial year %q, want e.g. 2025-26", s)
+	}
+	if (start+1)%100 != end {
+		return FiscalYear{}, fmt.Errorf("invalid financial year %q: years are not consecutive", s)
+	}
+	return fiscalYear(start), nil
+}
+
+// FYOf returns the financial year containing t.
+func FYOf(t time.Time) FiscalYear {
+	t = t.In(IST)
+	y := t.Year()
+	if t.Month() < time.April {
+		y--
+	}
+	return fiscalYear(y)
+}
+
+func fiscalYear(start int) FiscalYear {
+	return FiscalYear{
+		Label: fmt.Sprintf("%d-%02d", start, (start+1)%100),
+		Start: time.Date(start, time.April, 1, 0, 0, 0, 0, IST),
+		End:   time.Date(start+1, time.April, 1, 0, 0, 0, 0, IST),
+	}
+}
+
+// HoldingDays counts calendar days in India between acquisition and sale.
+func HoldingDays(acquired, sold time.Time) int {
+	a, s := acquired.In(IST), sold.In(IST)
+	da := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	ds := time.Date(s.Year(), s.Month(), s.Day(), 0, 0, 0, 0, time.UTC)
+	return int(ds.Sub(da).Hours() / 24)
+}
+
+// Term classifies a sale of listed equity: gains on shares held for more
+// than twelve months are long-term.
+func Term(acquired, sold time.Time) string {
+	a, s := acquired.In(IST), sold.In(IST)
+	anniversary := time.Date(a.Year()+1, a.Month(), a.Day(), 0, 0, 0, 0, IST)
+	sday := time.Date(s.Year(), s.Month(), s.Day(), 0, 0, 0, 0, IST)
+	if sday.After(anniversary) {
+		return LongTerm
+	}
+	return ShortTerm
+}
+
+// Disposal is part of one lot sold in one sale.
+type Disposal struct {
+	Symbol      string
+	AcquiredAt  time.Time
+	SoldAt      time.Time
+	Quantity    decimal.Decimal
+	CostINR     decimal.Decimal
+	ProceedsINR decimal.Decimal
+}
+
+// Gain is a classified disposal.
+type Gain struct {
+	Symbol      string          `json:"symbol"`
+	AcquiredOn  string          `json:"acquired_on"`
+	SoldOn      string          `json:"sold_on"`
+	Quantity    decimal.Decimal `json:"quantity"`
+	HoldingDays int             `json:"holding_days"`
+	Term        string          `json:"term"`
+	CostINR     decimal.Decimal `json:"cost_inr"`
+	ProceedsINR decimal.Decimal `json:"proceeds_inr"`
+	GainINR     decimal.Decimal `json:"gain_inr"`
+}
+
+type Report struct {
+	FY          string          `json:"fy"`
+	Gains       []Gain          `json:"gains"`
+	STCGINR     decimal.Decimal `json:"stcg_inr"`
+	LTCGINR     decimal.Decimal `json:"ltcg_inr"`
+	TotalINR    decimal.Decimal `json:"total_inr"`
+	ProceedsINR decimal.Decimal `json:"proceeds_inr"`
+	CostINR     decimal.Decimal `json:"cost_inr"`
+}
+
+// Build classifies the disposals sold within fy and totals them by term.
+func Build(fy FiscalYear, disposals []Disposal) Report {
+	rep := Report{FY: fy.Label, Gains: []Gain{}}
+	for _, d := range disposals {
+		if d.SoldAt.Before(fy.Start) || !d.SoldAt.Before(fy.End) {
+			continue
+		}
+		g := Gain{
+			Symbol:      d.Symbol,
+			AcquiredOn:  d.AcquiredAt.In(IST).Format("2006-01-02"),
+			SoldOn:      d.SoldAt.In(IST).Format("2006-01-02"),
+			Quantity:    d.Quantity,
+			HoldingDays: HoldingDays(d.AcquiredAt, d.SoldAt),
+			Term:        Term(d.AcquiredAt, d.SoldAt),
+			CostINR:     d.CostINR,
+			ProceedsINR: d.ProceedsINR,
+			GainINR:     d.ProceedsINR.Sub(d.CostINR),
+		}
+		if g.Term == LongTerm {
+			rep.LTCGINR = rep.LTCGINR.Add(g.GainINR)
+		} else {
+			rep.STCGINR = rep.STCGINR.Add(g.GainINR)
+		}
+		rep.ProceedsINR = rep.ProceedsINR.Add(g.ProceedsINR)
+		rep.CostINR = rep.CostINR.Add(g.CostINR)
+		rep.Gains = append(rep.Gains, g)
+	}
+	rep.TotalINR = rep.STCGINR.Add(rep.LTCGINR)
+	return rep
+}
+
+// CSVHeader and CSVRow lay out a report's gains for export.
+var CSVHeader = []string{"symbol", "acquired_on", "sold_on", "quantity", "holding_days", "term", "cost_inr", "proceeds_inr", "gain_inr"}
+
+func (g Gain) CSVRow() []string {
+	return []string{
+		g.Symbol, g.AcquiredOn, g.SoldOn, g.Quantity.StringFixed(6), fmt.Sprint(g.HoldingDays), g.Term,
+		g.CostINR.StringFixed(4), g.ProceedsINR.StringFixed(4), g.GainINR.StringFixed(4),
+	}
+}
@@ -0,0 +1,78 @@
+package tax
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestParseFY(t *testing.T) {
+	fy, err := ParseFY("2025-26")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2025, 4, 1, 0, 0, 0, 0, IST); !fy.Start.Equal(want) {
+		t.Errorf("start = %v, want %v", fy.Start, want)
+	}
+	if want := time.Date(2026, 4, 1, 0, 0, 0, 0, IST); !fy.End.Equal(want) {
+		t.Errorf("end = %v, want %v", fy.End, want)
+	}
+	for _, bad := range []string{"2025-27", "2025", "25-26", "2025-2026", "abcd-ef"} {
+		if _, err := ParseFY(bad); err == nil {
+			t.Errorf("ParseFY(%q) should fail", bad)
+		}
+	}
+	if fy, _ := ParseFY("2099-00"); fy.End.Year() != 2100 {
+		t.Errorf("century rollover: %+v", fy)
+	}
+}
+
+func TestFYOf(t *testing.T) {
+	// 31 March 20:00 UTC is already 1 April in India.
+	if got := FYOf(time.Date(2026, 3, 31, 20, 0, 0, 0, time.UTC)).Label; got != "2026-27" {
+		t.Errorf("FYOf = %s, want 2026-27", got)
+	}
+	if got := FYOf(time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)).Label; got != "2025-26" {
+		t.Errorf("FYOf = %s, want 2025-26", got)
+	}
+}
+
+func TestTerm(t *testing.T) {
+	acquired := time.Date(2024, 6, 10, 10, 0, 0, 0, IST)
+	cases := []struct {
+		sold time.Time
+		want string
+	}{
+		{time.Date(2025, 6, 10, 15, 0, 0, 0, IST), ShortTerm},
+		{time.Date(2025, 6, 11, 9, 0, 0, 0, IST), LongTerm},
+		{time.Date(2024, 12, 1, 9, 0, 0, 0, IST), ShortTerm},
+	}
+	for _, c := range cases {
+		if got := Term(acquired, c.sold); got != c.want {
+			t.Errorf("Term(%v) = %s, want %s", c.sold, got, c.want)
+		}
+	}
+	if got := HoldingDays(acquired, time.Date(2025, 6, 11, 9, 0, 0, 0, IST)); got != 366 {
+		t.Errorf("HoldingDays = %d, want 366", got)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	fy, _ := ParseFY("2025-26")
+	d := func(acq, sold time.Time, cost, proceeds string) Disposal {
+		return Disposal{Symbol: "TCS", AcquiredAt: acq, SoldAt: sold, Quantity: decimal.NewFromInt(1),
+			CostINR: decimal.RequireFromString(cost), ProceedsINR: decimal.RequireFromString(proceeds)}
+	}
+	rep := Build(fy, []Disposal{
+		d(time.Date(2025, 5, 1, 0, 0, 0, 0, IST), time.Date(2025, 9, 1, 0, 0, 0, 0, IST), "100", "150"),
+		d(time.Date(2023, 5, 1, 0, 0, 0, 0, IST), time.Date(2025, 9, 1, 0, 0, 0, 0, IST), "100", "80"),
+		d(time.Date(2023, 5, 1, 0, 0, 0, 0, IST), time.Date(2025, 3, 31, 0, 0, 0, 0, IST), "100", "500"),
+	})
+	if len(rep.Gains) != 2 {
+		t.Fatalf("expected 2 gains in FY, got %d", len(rep.Gains))
+	}
+	if !rep.STCGINR.Equal(decimal.NewFromInt(50)) || !rep.LTCGINR.Equal(decimal.NewFromInt(-20)) || !rep.TotalINR.Equal(decimal.NewFromInt(30)) {
+		t.Fatalf("unexpected totals: stcg %s ltcg %s total %s", rep.STCGINR, rep.LTCGINR, rep.TotalINR)
+	}
+}
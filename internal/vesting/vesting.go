@@ -0,0 +1,62 @@
+package vesting
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Schedule releases a grant in equal monthly tranches over Months months
+// from the grant date. Nothing vests before CliffMonths; at the cliff the
+// tranches accrued so far vest at once.
+type Schedule struct {
+	CliffMonths int `json:"cliff_months"`
+	Months      int `json:"months"`
+}
+
+func (s Schedule) Validate() error {
+	if s.Months < 1 {
+		return errors.New("vesting months must be at least 1")
+	}
+	if s.CliffMonths < 0 || s.CliffMonths > s.Months {
+		return errors.New("vesting cliff must be between 0 and months")
+	}
+	return nil
+}
+
+// monthsElapsed counts the whole months between start and at.
+func monthsElapsed(start, at time.Time) int {
+	m := 0
+	for m < 1200 && !start.AddDate(0, m+1, 0).After(at) {
+		m++
+	}
+	return m
+}
+
+// VestedAt returns how much of total has vested by at. Tranches are
+// truncated to 6 decimal places and the last one carries the remainder,
+// so the full grant vests exactly after Months months.
+func (s Schedule) VestedAt(total decimal.Decimal, start, at time.Time) decimal.Decimal {
+	m := monthsElapsed(start, at)
+	if m < s.CliffMonths {
+		return decimal.Zero
+	}
+	if m >= s.Months {
+		return total
+	}
+	return total.Mul(decimal.NewFromInt(int64(m))).Div(decimal.NewFromInt(int64(s.Months))).Truncate(6)
+}
+
+// NextVest returns when the next tranche after at vests, or the zero time
+// once the grant is fully vested.
+func (s Schedule) NextVest(start, at time.Time) time.Time {
+	next := monthsElapsed(start, at) + 1
+	if next < s.CliffMonths {
+		next = s.CliffMonths
+	}
+	if next > s.Months {
+		return time.Time{}
+	}
+	return start.AddDate(0, next, 0)
+}
@@ -0,0 +1,57 @@
+package vesting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestVestedAt(t *testing.T) {
+	s := Schedule{CliffMonths: 3, Months: 12}
+	start := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	total := decimal.RequireFromString("10")
+	cases := []struct {
+		at   time.Time
+		want string
+	}{
+		{start, "0"},
+		{start.AddDate(0, 2, 27), "0"},
+		{start.AddDate(0, 3, 0), "2.5"},
+		{start.AddDate(0, 4, 0).Add(-time.Second), "2.5"},
+		{start.AddDate(0, 5, 0), "4.166666"},
+		{start.AddDate(0, 12, 0), "10"},
+		{start.AddDate(3, 0, 0), "10"},
+	}
+	for _, tc := range cases {
+		got := s.VestedAt(total, start, tc.at)
+		if !got.Equal(decimal.RequireFromString(tc.want)) {
+			t.Errorf("VestedAt(%s) = %s, want %s", tc.at.Format(time.RFC3339), got, tc.want)
+		}
+	}
+}
+
+func TestNextVest(t *testing.T) {
+	s := Schedule{CliffMonths: 3, Months: 12}
+	start := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	if got := s.NextVest(start, start); !got.Equal(start.AddDate(0, 3, 0)) {
+		t.Fatalf("first vest at %s, want cliff", got)
+	}
+	if got := s.NextVest(start, start.AddDate(0, 3, 0)); !got.Equal(start.AddDate(0, 4, 0)) {
+		t.Fatalf("vest after cliff at %s, want month 4", got)
+	}
+	if got := s.NextVest(start, start.AddDate(0, 12, 0)); !got.IsZero() {
+		t.Fatalf("fully vested grant has next vest %s", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, s := range []Schedule{{Months: 0}, {CliffMonths: -1, Months: 12}, {CliffMonths: 13, Months: 12}} {
+		if s.Validate() == nil {
+			t.Errorf("%+v should be invalid", s)
+		}
+	}
+	if err := (Schedule{CliffMonths: 12, Months: 12}).Validate(); err != nil {
+		t.Errorf("cliff at end should be valid: %v", err)
+	}
+}
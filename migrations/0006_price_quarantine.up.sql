@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS price_quarantine (
+  id BIGSERIAL PRIMARY KEY,
+  symbol TEXT NOT NULL REFERENCES stocks(symbol),
+  price_inr NUMERIC(18,4) NOT NULL,
+  last_price_inr NUMERIC(18,4),
+  move_pct NUMERIC(12,4),
+  reason TEXT NOT NULL,
+  timestamp TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS price_quarantine_symbol_idx ON price_quarantine (symbol, timestamp DESC);
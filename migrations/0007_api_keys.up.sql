@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  partner TEXT NOT NULL,
+  key_prefix TEXT NOT NULL,
+  key_hash TEXT NOT NULL UNIQUE,
+  allowed_sources TEXT[] NOT NULL DEFAULT '{}',
+  allowed_symbols TEXT[] NOT NULL DEFAULT '{}',
+  daily_quota INT NOT NULL DEFAULT 0,
+  rotated_from UUID REFERENCES api_keys(id),
+  created_at TIMESTAMPTZ DEFAULT now(),
+  last_used_at TIMESTAMPTZ,
+  revoked_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS api_key_usage (
+  api_key_id UUID NOT NULL REFERENCES api_keys(id),
+  day DATE NOT NULL,
+  count INT NOT NULL DEFAULT 0,
+  PRIMARY KEY (api_key_id, day)
+);
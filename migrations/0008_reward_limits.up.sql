@@ -0,0 +1,11 @@
+ALTER TABLE rewards ADD COLUMN IF NOT EXISTS price_inr NUMERIC(18,4);
+
+-- Backfill booking prices from the ledger: the purchase line is the cost plus fees.
+UPDATE rewards r SET price_inr = ROUND((
+    (SELECT SUM(amount_inr) FROM ledger_entries WHERE reward_id = r.id AND description = 'reward purchase')
+    - COALESCE((SELECT SUM(amount_inr) FROM ledger_entries WHERE reward_id = r.id AND description = 'fees for reward'), 0)
+  ) / NULLIF(r.quantity, 0), 4)
+WHERE r.price_inr IS NULL;
+
+CREATE INDEX IF NOT EXISTS rewards_user_created_idx ON rewards (user_id, created_at);
+CREATE INDEX IF NOT EXISTS rewards_source_created_idx ON rewards (source, created_at);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS approvals (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  reward_id UUID NOT NULL UNIQUE REFERENCES rewards(id),
+  status TEXT NOT NULL DEFAULT 'PENDING',
+  requested_by TEXT NOT NULL,
+  decided_by TEXT,
+  note TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  decided_at TIMESTAMPTZ,
+  CHECK (decided_by IS NULL OR decided_by <> requested_by)
+);
+CREATE INDEX IF NOT EXISTS approvals_status_idx ON approvals (status, created_at);
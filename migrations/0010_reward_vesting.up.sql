@@ -0,0 +1,16 @@
+ALTER TABLE rewards ADD COLUMN IF NOT EXISTS vesting_cliff_months INT;
+ALTER TABLE rewards ADD COLUMN IF NOT EXISTS vesting_months INT;
+
+CREATE TABLE IF NOT EXISTS reward_vesting (
+  reward_id UUID PRIMARY KEY REFERENCES rewards(id),
+  user_id TEXT NOT NULL REFERENCES users(id),
+  symbol TEXT NOT NULL REFERENCES stocks(symbol),
+  vested_quantity NUMERIC(18,6) NOT NULL DEFAULT 0,
+  lapsed_quantity NUMERIC(18,6) NOT NULL DEFAULT 0,
+  status TEXT NOT NULL DEFAULT 'VESTING',
+  next_vest_at TIMESTAMPTZ,
+  lapsed_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS reward_vesting_due_idx ON reward_vesting (next_vest_at) WHERE status = 'VESTING';
+CREATE INDEX IF NOT EXISTS reward_vesting_user_idx ON reward_vesting (user_id, symbol);
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS campaigns (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  name TEXT NOT NULL UNIQUE,
+  starts_at TIMESTAMPTZ NOT NULL,
+  ends_at TIMESTAMPTZ,
+  budget_inr NUMERIC(18,4) NOT NULL,
+  spent_inr NUMERIC(18,4) NOT NULL DEFAULT 0,
+  eligible_symbols TEXT[] NOT NULL DEFAULT '{}',
+  max_rewards_per_user INT NOT NULL DEFAULT 0,
+  max_inr_per_user NUMERIC(18,4) NOT NULL DEFAULT 0,
+  active BOOLEAN NOT NULL DEFAULT true,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CHECK (spent_inr >= 0 AND spent_inr <= budget_inr)
+);
+
+CREATE TABLE IF NOT EXISTS campaign_rules (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  campaign_id UUID NOT NULL REFERENCES campaigns(id),
+  event_type TEXT NOT NULL,
+  min_value NUMERIC(18,4) NOT NULL DEFAULT 0,
+  symbol TEXT NOT NULL REFERENCES stocks(symbol),
+  quantity NUMERIC(18,6) NOT NULL,
+  vesting_cliff_months INT,
+  vesting_months INT,
+  priority INT NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS campaign_rules_event_idx ON campaign_rules (event_type);
+
+ALTER TABLE rewards ADD COLUMN IF NOT EXISTS campaign_id UUID REFERENCES campaigns(id);
+CREATE INDEX IF NOT EXISTS rewards_campaign_user_idx ON rewards (campaign_id, user_id) WHERE campaign_id IS NOT NULL;
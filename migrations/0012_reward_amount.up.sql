@@ -0,0 +1 @@
+ALTER TABLE rewards ADD COLUMN IF NOT EXISTS requested_amount_inr NUMERIC(18,4);
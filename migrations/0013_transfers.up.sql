@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS transfers (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  from_user_id TEXT NOT NULL REFERENCES users(id),
+  to_user_id TEXT NOT NULL REFERENCES users(id),
+  symbol TEXT NOT NULL REFERENCES stocks(symbol),
+  quantity NUMERIC(18,6) NOT NULL CHECK (quantity > 0),
+  price_inr NUMERIC(18,4) NOT NULL,
+  kind TEXT NOT NULL DEFAULT 'gift',
+  note TEXT,
+  initiated_by TEXT NOT NULL,
+  idempotency_key TEXT UNIQUE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CHECK (from_user_id <> to_user_id)
+);
+CREATE INDEX IF NOT EXISTS transfers_from_idx ON transfers (from_user_id, created_at);
+CREATE INDEX IF NOT EXISTS transfers_to_idx ON transfers (to_user_id, created_at);
+
+-- Ledger entries not tied to a reward reference their source generically.
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS ref_type TEXT;
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS ref_id UUID;
+CREATE INDEX IF NOT EXISTS ledger_entries_ref_idx ON ledger_entries (ref_type, ref_id);
@@ -0,0 +1,28 @@
+ALTER TABLE holdings ADD COLUMN IF NOT EXISTS reserved_quantity NUMERIC(18,6) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS withdrawals (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id TEXT NOT NULL REFERENCES users(id),
+  symbol TEXT NOT NULL REFERENCES stocks(symbol),
+  quantity NUMERIC(18,6) NOT NULL CHECK (quantity > 0),
+  demat_account TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'REQUESTED',
+  idempotency_key TEXT UNIQUE,
+  external_ref TEXT,
+  failure_reason TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS withdrawals_user_idx ON withdrawals (user_id, created_at);
+CREATE INDEX IF NOT EXISTS withdrawals_status_idx ON withdrawals (status, created_at);
+
+CREATE TABLE IF NOT EXISTS withdrawal_transitions (
+  id BIGSERIAL PRIMARY KEY,
+  withdrawal_id UUID NOT NULL REFERENCES withdrawals(id),
+  from_status TEXT,
+  to_status TEXT NOT NULL,
+  actor TEXT NOT NULL,
+  note TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS withdrawal_transitions_idx ON withdrawal_transitions (withdrawal_id, id);
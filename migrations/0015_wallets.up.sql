@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS wallets (
+  user_id TEXT PRIMARY KEY REFERENCES users(id),
+  balance_inr NUMERIC(18,4) NOT NULL DEFAULT 0 CHECK (balance_inr >= 0),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS sales (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id TEXT NOT NULL REFERENCES users(id),
+  symbol TEXT NOT NULL REFERENCES stocks(symbol),
+  quantity NUMERIC(18,6) NOT NULL CHECK (quantity > 0),
+  price_inr NUMERIC(18,4) NOT NULL,
+  gross_inr NUMERIC(18,4) NOT NULL,
+  fees_inr NUMERIC(18,4) NOT NULL,
+  net_inr NUMERIC(18,4) NOT NULL,
+  initiated_by TEXT NOT NULL,
+  idempotency_key TEXT UNIQUE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS sales_user_idx ON sales (user_id, created_at);
+
+-- Every change to a wallet balance, with the balance it left behind.
+CREATE TABLE IF NOT EXISTS wallet_transactions (
+  id BIGSERIAL PRIMARY KEY,
+  user_id TEXT NOT NULL REFERENCES users(id),
+  amount_inr NUMERIC(18,4) NOT NULL,
+  balance_inr NUMERIC(18,4) NOT NULL,
+  ref_type TEXT NOT NULL,
+  ref_id UUID NOT NULL,
+  description TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS wallet_transactions_user_idx ON wallet_transactions (user_id, created_at);
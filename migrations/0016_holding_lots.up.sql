@@ -0,0 +1,29 @@
+-- A lot is one acquisition of shares at a known cost. Holdings are the sum of
+-- their open lots; outflows consume lots oldest first.
+CREATE TABLE IF NOT EXISTS holding_lots (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id TEXT NOT NULL REFERENCES users(id),
+  symbol TEXT NOT NULL REFERENCES stocks(symbol),
+  acquired_at TIMESTAMPTZ NOT NULL,
+  quantity NUMERIC(18,6) NOT NULL CHECK (quantity > 0),
+  remaining_quantity NUMERIC(18,6) NOT NULL CHECK (remaining_quantity >= 0),
+  cost_per_share_inr NUMERIC(18,4) NOT NULL,
+  source_type TEXT NOT NULL,
+  source_id UUID,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS holding_lots_open_idx ON holding_lots (user_id, symbol, acquired_at) WHERE remaining_quantity > 0;
+
+-- Existing holdings become one lot each, costed at the average booking price
+-- of the user's completed rewards in that symbol.
+INSERT INTO holding_lots (user_id, symbol, acquired_at, quantity, remaining_quantity, cost_per_share_inr, source_type)
+SELECT h.user_id, h.symbol,
+  COALESCE(MIN(rw.timestamp), h.last_updated),
+  h.quantity, h.quantity,
+  COALESCE(ROUND(SUM(rw.quantity * rw.price_inr) / NULLIF(SUM(rw.quantity), 0), 4), 0),
+  'backfill'
+FROM holdings h
+LEFT JOIN rewards rw ON rw.user_id = h.user_id AND rw.symbol = h.symbol AND rw.status = 'COMPLETED'
+WHERE h.quantity > 0
+  AND NOT EXISTS (SELECT 1 FROM holding_lots l WHERE l.user_id = h.user_id AND l.symbol = h.symbol)
+GROUP BY h.user_id, h.symbol, h.quantity, h.last_updated;
@@ -0,0 +1,15 @@
+-- The lots each sale consumed, with the cost and the share of the sale's net
+-- proceeds attributed to them, for capital gains reporting.
+CREATE TABLE IF NOT EXISTS lot_disposals (
+  id BIGSERIAL PRIMARY KEY,
+  sale_id UUID NOT NULL REFERENCES sales(id),
+  lot_id UUID NOT NULL REFERENCES holding_lots(id),
+  user_id TEXT NOT NULL REFERENCES users(id),
+  symbol TEXT NOT NULL REFERENCES stocks(symbol),
+  quantity NUMERIC(18,6) NOT NULL CHECK (quantity > 0),
+  acquired_at TIMESTAMPTZ NOT NULL,
+  sold_at TIMESTAMPTZ NOT NULL,
+  cost_inr NUMERIC(18,4) NOT NULL,
+  proceeds_inr NUMERIC(18,4) NOT NULL
+);
+CREATE INDEX IF NOT EXISTS lot_disposals_user_idx ON lot_disposals (user_id, sold_at);
@@ -0,0 +1,2 @@
+-- IANA zone a user's days follow; NULL means the server's default.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone TEXT;
@@ -0,0 +1,3 @@
+-- When a reward was reversed; NULL for rewards reversed before this column.
+ALTER TABLE rewards ADD COLUMN IF NOT EXISTS reversed_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS rewards_user_timestamp_idx ON rewards (user_id, timestamp DESC, id DESC);
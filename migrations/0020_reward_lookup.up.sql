@@ -0,0 +1,3 @@
+-- Support lookups of rewards by source and of a reward's lifecycle events.
+CREATE INDEX IF NOT EXISTS rewards_source_idx ON rewards (source, timestamp DESC);
+CREATE INDEX IF NOT EXISTS webhook_outbox_reward_idx ON webhook_outbox ((payload->>'reward_id'));
@@ -0,0 +1,7 @@
+-- Why a reward was reversed and by whom. Reversals made before this
+-- migration have no reason.
+ALTER TABLE rewards ADD COLUMN IF NOT EXISTS reversal_reason TEXT
+  CHECK (reversal_reason IN ('fraud', 'duplicate', 'ops_error', 'user_request'));
+ALTER TABLE rewards ADD COLUMN IF NOT EXISTS reversal_note TEXT;
+ALTER TABLE rewards ADD COLUMN IF NOT EXISTS reversed_by TEXT;
+CREATE INDEX IF NOT EXISTS rewards_reversal_idx ON rewards (reversal_reason, reversed_at) WHERE status = 'REVERSED';
@@ -0,0 +1,14 @@
+-- Stock master data. Rewards may only be granted in active stocks; delisted
+-- stocks keep their history and holdings but stop receiving prices.
+ALTER TABLE stocks ADD COLUMN IF NOT EXISTS isin TEXT UNIQUE;
+ALTER TABLE stocks ADD COLUMN IF NOT EXISTS exchange TEXT NOT NULL DEFAULT 'NSE';
+ALTER TABLE stocks ADD COLUMN IF NOT EXISTS sector TEXT;
+ALTER TABLE stocks ADD COLUMN IF NOT EXISTS lot_precision INT NOT NULL DEFAULT 6 CHECK (lot_precision BETWEEN 0 AND 6);
+ALTER TABLE stocks ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE stocks ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE stocks ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+-- Fill in the stocks the server used to seed with only a symbol and name.
+UPDATE stocks SET isin = 'INE002A01018', sector = 'Energy' WHERE symbol = 'RELIANCE' AND isin IS NULL;
+UPDATE stocks SET isin = 'INE467B01029', sector = 'Information Technology' WHERE symbol = 'TCS' AND isin IS NULL;
+UPDATE stocks SET isin = 'INE009A01021', sector = 'Information Technology' WHERE symbol = 'INFY' AND isin IS NULL;
@@ -0,0 +1,5 @@
+-- A non-positive reward would take shares out of holdings and lower the
+-- usage that reward limits are checked against. NOT VALID leaves any
+-- existing rows alone and checks every new or updated one.
+ALTER TABLE rewards DROP CONSTRAINT IF EXISTS rewards_quantity_positive;
+ALTER TABLE rewards ADD CONSTRAINT rewards_quantity_positive CHECK (quantity > 0) NOT VALID;
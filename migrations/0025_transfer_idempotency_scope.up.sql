@@ -0,0 +1,5 @@
+-- Idempotency keys are chosen by the sender's client, so they are only
+-- unique per sender: another user reusing a key must not be handed this
+-- sender's transfer.
+ALTER TABLE transfers DROP CONSTRAINT IF EXISTS transfers_idempotency_key_key;
+CREATE UNIQUE INDEX IF NOT EXISTS transfers_from_idempotency_idx ON transfers (from_user_id, idempotency_key);
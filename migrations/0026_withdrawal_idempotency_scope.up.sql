@@ -0,0 +1,5 @@
+-- Idempotency keys are chosen by each user's client, so they are only
+-- unique per user: another user reusing a key must not be handed this
+-- user's withdrawal.
+ALTER TABLE withdrawals DROP CONSTRAINT IF EXISTS withdrawals_idempotency_key_key;
+CREATE UNIQUE INDEX IF NOT EXISTS withdrawals_user_idempotency_idx ON withdrawals (user_id, idempotency_key);
@@ -0,0 +1,5 @@
+-- Idempotency keys are chosen by each user's client, so they are only
+-- unique per user: another user reusing a key must not be handed this
+-- user's sale.
+ALTER TABLE sales DROP CONSTRAINT IF EXISTS sales_idempotency_key_key;
+CREATE UNIQUE INDEX IF NOT EXISTS sales_user_idempotency_idx ON sales (user_id, idempotency_key);
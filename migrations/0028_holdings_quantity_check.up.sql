@@ -0,0 +1,5 @@
+-- Holdings can never go negative or have more shares reserved for
+-- withdrawals than are held. NOT VALID leaves any existing rows alone and
+-- checks every new or updated one.
+ALTER TABLE holdings DROP CONSTRAINT IF EXISTS holdings_quantity_reserved_check;
+ALTER TABLE holdings ADD CONSTRAINT holdings_quantity_reserved_check CHECK (quantity >= 0 AND reserved_quantity <= quantity) NOT VALID;